@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"temporal-aone/backend/pkg"
+	"temporal-aone/backend/shared"
+
+	"github.com/gin-gonic/gin"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// webhook 请求体大小上限
+const maxWebhookBody = 5 << 20
+
+// ApplicationRequest 应用配置，Token 和 WebhookSecret 为空时保留原值
+type ApplicationRequest struct {
+	RepoURL        string   `json:"repo_url" binding:"required"`
+	UserName       string   `json:"user_name"`
+	Token          string   `json:"token"`
+	WebhookSecret  string   `json:"webhook_secret"`
+	Branches       []string `json:"branches"`
+	BuildTags      bool     `json:"build_tags"`
+	BinaryPath     string   `json:"binary_path"`
+	ConfigFilePath string   `json:"config_file_path"`
+	ECSUploadPath  string   `json:"ecs_upload_path"`
+	ECSUser        string   `json:"ecs_user"`
+	ECSServer      string   `json:"ecs_server"`
+	Environment    string   `json:"environment"`
+
+	// 分支构建的版本策略：timestamp 或 semver，为空时使用短提交号
+	VersionStrategy string `json:"version_strategy"`
+	VersionPrefix   string `json:"version_prefix"`
+	TagReleases     bool   `json:"tag_releases"`
+}
+
+// Handler for listing the registered applications
+func listApplications(c *gin.Context) {
+	var apps []pkg.Application
+	if err := shared.GetDB().Order("name").Find(&apps).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"applications": apps})
+}
+
+// Handler for fetching one application
+func getApplication(c *gin.Context) {
+	var app pkg.Application
+	err := shared.GetDB().Where("name = ?", c.Param("app")).First(&app).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "application not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, app)
+}
+
+// Handler for creating or updating an application
+func putApplication(c *gin.Context) {
+	var req ApplicationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	app := pkg.Application{
+		Name:           c.Param("app"),
+		RepoURL:        req.RepoURL,
+		RepoKey:        pkg.NormalizeRepoURL(req.RepoURL),
+		UserName:       req.UserName,
+		Token:          req.Token,
+		WebhookSecret:  req.WebhookSecret,
+		Branches:       req.Branches,
+		BuildTags:      req.BuildTags,
+		BinaryPath:     req.BinaryPath,
+		ConfigFilePath: req.ConfigFilePath,
+		ECSUploadPath:  req.ECSUploadPath,
+		ECSUser:        req.ECSUser,
+		ECSServer:      req.ECSServer,
+		Environment:    req.Environment,
+
+		VersionStrategy: req.VersionStrategy,
+		VersionPrefix:   req.VersionPrefix,
+		TagReleases:     req.TagReleases,
+	}
+	switch req.VersionStrategy {
+	case "", pkg.VersionTimestamp, pkg.VersionSemver:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported version_strategy %q", req.VersionStrategy)})
+		return
+	}
+	columns := []string{
+		"repo_url", "repo_key", "user_name", "branches", "build_tags", "binary_path", "config_file_path",
+		"ecs_upload_path", "ecs_user", "ecs_server", "environment",
+		"version_strategy", "version_prefix", "tag_releases", "updated_at",
+	}
+	// 密钥只在提供时更新
+	if req.Token != "" {
+		columns = append(columns, "token")
+	}
+	if req.WebhookSecret != "" {
+		columns = append(columns, "webhook_secret")
+	}
+	err := shared.GetDB().Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns(columns),
+	}).Create(&app).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, app)
+}
+
+// Handler for deleting an application
+func deleteApplication(c *gin.Context) {
+	result := shared.GetDB().Where("name = ?", c.Param("app")).Delete(&pkg.Application{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "application not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// findApplicationByRepo 按事件中的任一仓库地址查找应用
+func findApplicationByRepo(urls []string) (pkg.Application, error) {
+	var keys []string
+	for _, u := range urls {
+		if key := pkg.NormalizeRepoURL(u); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	var app pkg.Application
+	if len(keys) == 0 {
+		return app, gorm.ErrRecordNotFound
+	}
+	err := shared.GetDB().Where("repo_key IN ?", keys).First(&app).Error
+	return app, err
+}
+
+// Handler for GitHub, GitLab and Gitea push/tag webhooks; verifies the
+// signature with the application's secret and starts BuildUploadWorkflow
+// for the pushed commit
+func receiveGitWebhook(c *gin.Context) {
+	provider := pkg.DetectProvider(c.Request.Header)
+	if provider == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown webhook provider"})
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, err := pkg.ParsePushEvent(provider, c.Request.Header, body)
+	if errors.Is(err, pkg.ErrUnsupportedEvent) {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored", "reason": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	app, err := findApplicationByRepo(event.RepoURLs)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no application registered for this repository"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !pkg.VerifyWebhookSignature(provider, app.WebhookSecret, c.Request.Header, body) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid webhook signature"})
+		return
+	}
+	if !app.ShouldBuild(event) {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored", "reason": fmt.Sprintf("%s does not trigger builds", event.Ref)})
+		return
+	}
+
+	config := app.BuildConfig(event)
+	if app.Environment != "" {
+		envs, err := pkg.LoadPipeline(shared.GetDB(), app.Name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, env := range envs {
+			if env.Name == app.Environment {
+				config = env.Apply(config)
+			}
+		}
+	}
+	config.Steps = stepOptionsFromConfig(shared.Config.Activities)
+
+	temporalClient := getTemporalClient(c)
+	// 同一提交的重复投递不会重复构建，版本号由工作流生成时以提交号去重
+	key := config.Version
+	if key == "" {
+		key = event.Commit
+	}
+	options := client.StartWorkflowOptions{
+		ID:        fmt.Sprintf("webhook-%s-%s", app.Name, key),
+		TaskQueue: shared.Config.Temporal.TaskQueue,
+	}
+	we, err := temporalClient.ExecuteWorkflow(context.Background(), options, pkg.BuildUploadWorkflow, config)
+	var started *serviceerror.WorkflowExecutionAlreadyStarted
+	if errors.As(err, &started) {
+		c.JSON(http.StatusOK, gin.H{"status": "already running", "workflow_id": options.ID})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":      "started",
+		"workflow_id": we.GetID(),
+		"run_id":      we.GetRunID(),
+		"ref":         event.Ref,
+		"commit":      event.Commit,
+	})
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"temporal-aone/backend/pkg"
+	"temporal-aone/backend/shared"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.temporal.io/sdk/client"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 同步查询主机版本目录的超时时间
+const listReleasesTimeout = 2 * time.Minute
+
+// EnvironmentRequest 环境配置
+type EnvironmentRequest struct {
+	Position          int      `json:"position"`
+	Hosts             []string `json:"hosts" binding:"required,min=1"`
+	ECSUser           string   `json:"ecs_user"`
+	ECSUploadPath     string   `json:"ecs_upload_path"`
+	DeployMode        string   `json:"deploy_mode"`
+	HealthCheckURL    string   `json:"health_check_url"`
+	VersionedReleases bool     `json:"versioned_releases"`
+	KeepReleases      int      `json:"keep_releases"`
+}
+
+// PromotionRequest 晋级请求，To 为空时晋级到 From 的下一个环境
+type PromotionRequest struct {
+	Version string `json:"version" binding:"required"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Actor   string `json:"actor"`
+
+	WaitForWindow bool             `json:"wait_for_window"`
+	Override      *OverrideRequest `json:"override"`
+}
+
+// Handler for listing the environments of an application in promotion order
+func listEnvironments(c *gin.Context) {
+	envs, err := pkg.LoadPipeline(shared.GetDB(), c.Param("app"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"environments": envs})
+}
+
+// Handler for creating or updating an environment
+func putEnvironment(c *gin.Context) {
+	var req EnvironmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	env := pkg.Environment{
+		App:               c.Param("app"),
+		Name:              c.Param("env"),
+		Position:          req.Position,
+		Hosts:             req.Hosts,
+		ECSUser:           req.ECSUser,
+		ECSUploadPath:     req.ECSUploadPath,
+		DeployMode:        req.DeployMode,
+		HealthCheckURL:    req.HealthCheckURL,
+		VersionedReleases: req.VersionedReleases,
+		KeepReleases:      req.KeepReleases,
+	}
+	err := shared.GetDB().Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{
+			"position", "hosts", "ecs_user", "ecs_upload_path", "deploy_mode", "health_check_url",
+			"versioned_releases", "keep_releases", "updated_at",
+		}),
+	}).Create(&env).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, env)
+}
+
+// Handler for deleting an environment
+func deleteEnvironment(c *gin.Context) {
+	result := shared.GetDB().
+		Where("app = ? AND name = ?", c.Param("app"), c.Param("env")).
+		Delete(&pkg.Environment{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "environment not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Handler for listing the release directories and the current release on
+// every host of an environment; the hosts are queried over SSH by a worker
+func listHostReleases(c *gin.Context) {
+	var env pkg.Environment
+	err := shared.GetDB().Where("app = ? AND name = ?", c.Param("app"), c.Param("env")).First(&env).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "environment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !env.VersionedReleases {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "environment does not use versioned releases"})
+		return
+	}
+	config := env.Apply(pkg.Config{Steps: stepOptionsFromConfig(shared.Config.Activities)})
+
+	temporalClient := getTemporalClient(c)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), listReleasesTimeout)
+	defer cancel()
+	options := client.StartWorkflowOptions{
+		ID:                       fmt.Sprintf("list-releases-%s-%s", env.App, env.Name),
+		TaskQueue:                shared.Config.Temporal.TaskQueue,
+		WorkflowExecutionTimeout: listReleasesTimeout,
+	}
+	we, err := temporalClient.ExecuteWorkflow(ctx, options, pkg.ListReleasesWorkflow, config)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var hosts []pkg.HostReleases
+	if err := we.Get(ctx, &hosts); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "workflow_id": we.GetID()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"hosts": hosts})
+}
+
+// Handler for promoting an already built version to the next environment
+func promoteRelease(c *gin.Context) {
+	var req PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	app := c.Param("app")
+	db := shared.GetDB()
+
+	envs, err := pkg.LoadPipeline(db, app)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var target pkg.Environment
+	var ok bool
+	switch {
+	case req.From != "":
+		target, ok = pkg.NextEnvironment(envs, req.From)
+	case req.To != "":
+		for _, env := range envs {
+			if env.Name == req.To {
+				target, ok = env, true
+			}
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "either from or to is required"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "target environment not found"})
+		return
+	}
+	// 只能晋级到紧邻的下一个环境
+	if req.From != "" && req.To != "" && req.To != target.Name {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%s is not the next environment after %s", req.To, req.From)})
+		return
+	}
+
+	release, err := pkg.CheckPromotion(db, envs, target, req.Version)
+	if errors.Is(err, pkg.ErrPromotionNotAllowed) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// 晋级发布上一个环境记录的产物，Worker 按校验和从产物仓库取回
+	artifact, ok, err := pkg.FindArtifact(db, release)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("no artifact recorded for %s in %s", req.Version, release.Environment)})
+		return
+	}
+
+	config := target.Apply(pkg.Config{
+		RepoURL:          release.RepoURL,
+		Tag:              release.Tag,
+		BinaryPath:       release.BinaryPath,
+		BinaryChecksum:   artifact.BinaryChecksum,
+		TemplateChecksum: artifact.TemplateChecksum,
+		ConfigFilePath:   release.ConfigFilePath,
+		LocalPath:        release.LocalPath,
+		Commit:           release.Commit,
+		Version:          req.Version,
+		Actor:            req.Actor,
+		WaitForWindow:    req.WaitForWindow,
+		Override:         req.Override.toSpec(),
+		Steps:            stepOptionsFromConfig(shared.Config.Activities),
+	})
+	if !checkPolicy(c, config) {
+		return
+	}
+
+	temporalClient := getTemporalClient(c)
+	options := client.StartWorkflowOptions{
+		ID:        fmt.Sprintf("promote-%s-%s-%s", app, target.Name, req.Version),
+		TaskQueue: shared.Config.Temporal.TaskQueue,
+	}
+	we, err := temporalClient.ExecuteWorkflow(context.Background(), options, pkg.PromoteWorkflow, config)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"workflow_id":   we.GetID(),
+		"run_id":        we.GetRunID(),
+		"environment":   target.Name,
+		"promoted_from": release.Environment,
+	})
+}
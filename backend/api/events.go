@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"temporal-aone/backend/pkg"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+)
+
+// 进度轮询间隔
+const eventPollInterval = time.Second
+
+// 连续轮询失败达到次数后结束推送，偶发的失败（重连、查询超时）只发送 warning
+const eventMaxFailures = 5
+
+// workflowEventStream 记录已经推送给前端的状态，只发送增量事件
+type workflowEventStream struct {
+	// 每次轮询时取当前客户端，推送期间 Temporal 重连也不受影响
+	clients    func() (client.Client, error)
+	workflowID string
+	runID      string
+
+	stage string
+	// 已推送的日志总条数，进度中较早的日志可能已被丢弃
+	sentLogs   int
+	heartbeats map[string]string
+	failures   int
+}
+
+type workflowEvent struct {
+	name string
+	data interface{}
+}
+
+// poll 查询工作流进度和正在执行的活动心跳，返回新事件以及工作流是否已经结束
+func (s *workflowEventStream) poll(ctx context.Context) ([]workflowEvent, bool, error) {
+	temporalClient, err := s.clients()
+	if err != nil {
+		return nil, false, err
+	}
+	desc, err := temporalClient.DescribeWorkflowExecution(ctx, s.workflowID, s.runID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var events []workflowEvent
+	var progress pkg.Progress
+	value, err := temporalClient.QueryWorkflow(ctx, s.workflowID, s.runID, pkg.ProgressQueryName)
+	if err == nil {
+		err = value.Get(&progress)
+	}
+	if err != nil {
+		// 查询依赖 Worker 在线，失败时仅提示，不中断推送
+		events = append(events, workflowEvent{name: "warning", data: gin.H{"error": err.Error()}})
+	} else {
+		if progress.Stage != s.stage {
+			s.stage = progress.Stage
+			events = append(events, workflowEvent{name: "stage", data: gin.H{
+				"stage":       progress.Stage,
+				"step":        progress.Step,
+				"total_steps": progress.TotalSteps,
+				"status":      progress.Status,
+			}})
+		}
+		if s.sentLogs < progress.DroppedLogs {
+			s.sentLogs = progress.DroppedLogs
+		}
+		for ; s.sentLogs < progress.DroppedLogs+len(progress.Logs); s.sentLogs++ {
+			events = append(events, workflowEvent{name: "log", data: progress.Logs[s.sentLogs-progress.DroppedLogs]})
+		}
+	}
+
+	for _, pa := range desc.GetPendingActivities() {
+		if pa.GetHeartbeatDetails() == nil {
+			continue
+		}
+		var details pkg.ActivityProgress
+		if err := converter.GetDefaultDataConverter().FromPayloads(pa.GetHeartbeatDetails(), &details); err != nil {
+			continue
+		}
+		if s.heartbeats[pa.GetActivityId()] == details.Message {
+			continue
+		}
+		s.heartbeats[pa.GetActivityId()] = details.Message
+		events = append(events, workflowEvent{name: "heartbeat", data: gin.H{
+			"activity": pa.GetActivityType().GetName(),
+			"attempt":  pa.GetAttempt(),
+			"message":  details.Message,
+			"time":     details.Time,
+		}})
+	}
+
+	status := desc.GetWorkflowExecutionInfo().GetStatus()
+	if status != enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING {
+		events = append(events, workflowEvent{name: "done", data: gin.H{
+			"status": status.String(),
+			"error":  progress.Error,
+		}})
+		return events, true, nil
+	}
+	return events, false, nil
+}
+
+// next 轮询一次并处理失败：工作流不存在或连续失败 eventMaxFailures 次时发送 error 并结束，否则发送 warning 继续
+func (s *workflowEventStream) next(ctx context.Context) ([]workflowEvent, bool) {
+	events, done, err := s.poll(ctx)
+	if err == nil {
+		s.failures = 0
+		return events, done
+	}
+	s.failures++
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) || s.failures >= eventMaxFailures {
+		return []workflowEvent{{name: "error", data: gin.H{"error": err.Error()}}}, true
+	}
+	return []workflowEvent{{name: "warning", data: gin.H{"error": err.Error(), "failures": s.failures}}}, false
+}
+
+// Handler for streaming workflow progress over Server-Sent Events
+func streamWorkflowEvents(c *gin.Context) {
+	stream := &workflowEventStream{
+		clients:    getTemporalConn(c).Client,
+		workflowID: c.Param("id"),
+		runID:      c.Query("run_id"),
+		heartbeats: make(map[string]string),
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	ticker := time.NewTicker(eventPollInterval)
+	defer ticker.Stop()
+
+	first := true
+	c.Stream(func(w io.Writer) bool {
+		if !first {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case <-ticker.C:
+			}
+		}
+		first = false
+
+		events, done := stream.next(c.Request.Context())
+		for _, event := range events {
+			c.SSEvent(event.name, event.data)
+		}
+		return !done
+	})
+}
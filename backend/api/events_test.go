@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"temporal-aone/backend/pkg"
+	"testing"
+
+	commonpb "go.temporal.io/api/common/v1"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+)
+
+// fakeProgressClient 返回预设的工作流状态、进度和活动心跳
+type fakeProgressClient struct {
+	client.Client
+
+	status      enumspb.WorkflowExecutionStatus
+	progress    pkg.Progress
+	describeErr error
+	queryErr    error
+	heartbeats  map[string]string
+}
+
+func (f *fakeProgressClient) DescribeWorkflowExecution(ctx context.Context, workflowID, runID string) (*workflowservice.DescribeWorkflowExecutionResponse, error) {
+	if f.describeErr != nil {
+		return nil, f.describeErr
+	}
+	resp := &workflowservice.DescribeWorkflowExecutionResponse{
+		WorkflowExecutionInfo: &workflowpb.WorkflowExecutionInfo{Status: f.status},
+	}
+	for id, message := range f.heartbeats {
+		details, err := converter.GetDefaultDataConverter().ToPayloads(pkg.ActivityProgress{Message: message})
+		if err != nil {
+			return nil, err
+		}
+		resp.PendingActivities = append(resp.PendingActivities, &workflowpb.PendingActivityInfo{
+			ActivityId:       id,
+			ActivityType:     &commonpb.ActivityType{Name: "BuildActivity"},
+			HeartbeatDetails: details,
+			Attempt:          1,
+		})
+	}
+	return resp, nil
+}
+
+func (f *fakeProgressClient) QueryWorkflow(ctx context.Context, workflowID, runID, queryType string, args ...interface{}) (converter.EncodedValue, error) {
+	if f.queryErr != nil {
+		return nil, f.queryErr
+	}
+	payloads, err := converter.GetDefaultDataConverter().ToPayloads(f.progress)
+	if err != nil {
+		return nil, err
+	}
+	return client.NewValue(payloads), nil
+}
+
+func eventNames(events []workflowEvent) []string {
+	names := []string{}
+	for _, event := range events {
+		names = append(names, event.name)
+	}
+	return names
+}
+
+func TestWorkflowEventStreamPoll(t *testing.T) {
+	fake := &fakeProgressClient{status: enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING}
+	stream := &workflowEventStream{
+		clients:    func() (client.Client, error) { return fake, nil },
+		workflowID: "build-upload-app",
+		heartbeats: make(map[string]string),
+	}
+	logs := func(messages ...string) []pkg.LogLine {
+		var lines []pkg.LogLine
+		for _, message := range messages {
+			lines = append(lines, pkg.LogLine{Message: message})
+		}
+		return lines
+	}
+
+	// 每一步修改假客户端的状态，只应推送与上一次轮询相比的增量
+	tests := []struct {
+		name     string
+		update   func()
+		want     []string
+		wantDone bool
+	}{
+		{"first poll sends stage and logs", func() {
+			fake.progress = pkg.Progress{Stage: "build", Step: 1, Logs: logs("stage build started")}
+		}, []string{"stage", "log"}, false},
+		{"nothing changed", func() {}, []string{}, false},
+		{"new log and heartbeat", func() {
+			fake.progress.Logs = logs("stage build started", "go build")
+			fake.heartbeats = map[string]string{"5": "compiling"}
+		}, []string{"log", "heartbeat"}, false},
+		{"same heartbeat is not repeated", func() {}, []string{}, false},
+		{"query failure only warns", func() {
+			fake.queryErr = errors.New("no worker")
+		}, []string{"warning"}, false},
+		{"new stage after query recovers", func() {
+			fake.queryErr = nil
+			fake.heartbeats = map[string]string{"5": "uploading"}
+			fake.progress = pkg.Progress{Stage: "upload", Step: 2, Logs: logs("stage build started", "go build", "stage upload started")}
+		}, []string{"stage", "log", "heartbeat"}, false},
+		{"older logs dropped from progress", func() {
+			fake.progress.DroppedLogs = 2
+			fake.progress.Logs = logs("stage upload started", "uploading", "uploaded")
+		}, []string{"log", "log"}, false},
+		{"finished", func() {
+			fake.status = enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED
+			fake.heartbeats = nil
+			fake.progress.Status = pkg.ProgressCompleted
+			fake.progress.Logs = append(fake.progress.Logs, pkg.LogLine{Message: "workflow completed"})
+		}, []string{"log", "done"}, true},
+	}
+
+	for _, tt := range tests {
+		tt.update()
+		events, done, err := stream.poll(context.Background())
+		if err != nil {
+			t.Fatalf("%s: poll() error = %v", tt.name, err)
+		}
+		if got := eventNames(events); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: events = %v, want %v", tt.name, got, tt.want)
+		}
+		if done != tt.wantDone {
+			t.Errorf("%s: done = %v, want %v", tt.name, done, tt.wantDone)
+		}
+	}
+	if stream.sentLogs != 6 {
+		t.Errorf("sentLogs = %d, want 6", stream.sentLogs)
+	}
+}
+
+func TestWorkflowEventStreamPollUnavailable(t *testing.T) {
+	stream := &workflowEventStream{
+		clients:    func() (client.Client, error) { return nil, errTemporalUnavailable },
+		heartbeats: make(map[string]string),
+	}
+	if _, _, err := stream.poll(context.Background()); !errors.Is(err, errTemporalUnavailable) {
+		t.Errorf("poll() error = %v, want errTemporalUnavailable", err)
+	}
+}
+
+func TestWorkflowEventStreamNext(t *testing.T) {
+	fake := &fakeProgressClient{status: enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING}
+	stream := &workflowEventStream{
+		clients:    func() (client.Client, error) { return fake, nil },
+		heartbeats: make(map[string]string),
+	}
+
+	// 偶发失败只发送 warning，成功一次后重新计数
+	fake.describeErr = errors.New("connection is closing")
+	for i := 0; i < eventMaxFailures-1; i++ {
+		if events, done := stream.next(context.Background()); done || eventNames(events)[0] != "warning" {
+			t.Fatalf("failure %d: events = %v, done = %v, want a warning", i+1, eventNames(events), done)
+		}
+	}
+	fake.describeErr = nil
+	if _, done := stream.next(context.Background()); done || stream.failures != 0 {
+		t.Fatalf("successful poll: done = %v, failures = %d", done, stream.failures)
+	}
+
+	fake.describeErr = errors.New("deadline exceeded")
+	var events []workflowEvent
+	var done bool
+	for i := 0; i < eventMaxFailures; i++ {
+		events, done = stream.next(context.Background())
+	}
+	if !done || !reflect.DeepEqual(eventNames(events), []string{"error"}) {
+		t.Errorf("after %d failures: events = %v, done = %v, want error and done", eventMaxFailures, eventNames(events), done)
+	}
+
+	stream.failures = 0
+	fake.describeErr = serviceerror.NewNotFound("workflow not found")
+	if events, done := stream.next(context.Background()); !done || !reflect.DeepEqual(eventNames(events), []string{"error"}) {
+		t.Errorf("not found: events = %v, done = %v, want error and done", eventNames(events), done)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"temporal-aone/backend/pkg"
+	"temporal-aone/backend/shared"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// inventoryFilter 从查询参数读取 app、environment 过滤条件
+func inventoryFilter(c *gin.Context) pkg.InventoryFilter {
+	return pkg.InventoryFilter{
+		App:         c.Query("app"),
+		Environment: c.Query("environment"),
+		Host:        c.Param("host"),
+	}
+}
+
+// Handler for listing releases, newest first, without changelogs
+func listReleases(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+		return
+	}
+	query := shared.GetDB().Omit("changelog")
+	if app := c.Query("app"); app != "" {
+		query = query.Where("app = ?", app)
+	}
+	if env, ok := c.GetQuery("environment"); ok {
+		query = query.Where("environment = ?", env)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var releases []pkg.Release
+	if err := query.Order("id desc").Limit(limit).Find(&releases).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"releases": releases})
+}
+
+// Handler for fetching one release with its per-host results
+func getRelease(c *gin.Context) {
+	release, ok := findRelease(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	var hosts []pkg.HostDeployment
+	if err := shared.GetDB().Where("release_id = ?", release.ID).Order("id").Find(&hosts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"release": release, "hosts": hosts})
+}
+
+// Handler for the version currently running on each host
+func getInventory(c *gin.Context) {
+	filter := inventoryFilter(c)
+	filter.Host = c.Query("host")
+	deployments, err := pkg.CurrentDeployments(shared.GetDB(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"hosts": deployments})
+}
+
+// Handler for the deployment history of one host
+func getHostHistory(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+		return
+	}
+	deployments, err := pkg.DeploymentHistory(shared.GetDB(), inventoryFilter(c), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"host": c.Param("host"), "deployments": deployments})
+}
+
+// findRelease 按 ID 查找发布记录，找不到时返回 404
+func findRelease(c *gin.Context, id string) (pkg.Release, bool) {
+	var release pkg.Release
+	err := shared.GetDB().First(&release, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "release " + id + " not found"})
+		return release, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return release, false
+	}
+	return release, true
+}
+
+// Handler for comparing two releases of the same application
+func diffReleases(c *gin.Context) {
+	from, ok := findRelease(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	to, ok := findRelease(c, c.Param("other"))
+	if !ok {
+		return
+	}
+	if from.App != to.App {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "releases belong to different applications"})
+		return
+	}
+	diff, err := pkg.DiffReleases(shared.GetDB(), from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"temporal-aone/backend/pkg"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler for listing the captured activity logs of a workflow run
+func listActivityLogs(c *gin.Context) {
+	logs, err := pkg.ActivityLogs.List(c.Param("id"), c.Param("run_id"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no logs for this run"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"logs": logs})
+}
+
+// Handler for fetching one activity log, either the last ?tail=N lines or
+// everything after ?offset=N so clients can poll for new output
+func getActivityLog(c *gin.Context) {
+	workflowID, runID, activityName := c.Param("id"), c.Param("run_id"), c.Param("activity")
+
+	if tail := c.Query("tail"); tail != "" {
+		n, err := strconv.Atoi(tail)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tail must be a positive integer"})
+			return
+		}
+		lines, err := pkg.ActivityLogs.Tail(workflowID, runID, activityName, n)
+		if err != nil {
+			respondLogError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"lines": lines})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.DefaultQuery("offset", "0"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+	data, next, err := pkg.ActivityLogs.Read(workflowID, runID, activityName, offset)
+	if err != nil {
+		respondLogError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"content":     string(data),
+		"next_offset": next,
+	})
+}
+
+func respondLogError(c *gin.Context, err error) {
+	if errors.Is(err, os.ErrNotExist) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "log not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -27,41 +27,362 @@ type WorkflowRequest struct {
 	BinaryPath     string `json:"binary_path"`
 	ConfigFilePath string `json:"config_file_path"`
 	Version        string `json:"version"`
-	ECSUploadPath  string `json:"ecs_upload_path"`
-	ECSServer      string `json:"ecs_server"`
-	ECSUser        string `json:"ecs_user"`
-	ECSPassword    string `json:"ecs_password"`
-	HealthCheckURL string `json:"health_check_url"`
+	// 版本号生成方式，为空时有 Version 则直接使用，否则使用应用的配置
+	Versioning     *VersioningRequest `json:"versioning"`
+	ECSUploadPath  string             `json:"ecs_upload_path"`
+	ECSServer      string             `json:"ecs_server"`
+	ECSUser        string             `json:"ecs_user"`
+	ECSPassword    string             `json:"ecs_password"`
+	HealthCheckURL string             `json:"health_check_url"`
+
+	// 按环境渲染配置模板，App 为空时使用仓库名
+	App         string `json:"app"`
+	Environment string `json:"environment"`
+
+	// 发起人，记录在发布记录中
+	Actor string `json:"actor"`
+
+	// 不在发布窗口内时等待，Override 需要管理员令牌
+	WaitForWindow bool             `json:"wait_for_window"`
+	Override      *OverrideRequest `json:"override"`
+
+	HealthCheck *HealthCheckRequest `json:"health_check"`
+
+	Hosts  []string       `json:"hosts"`
+	Canary *CanaryRequest `json:"canary"`
+
+	DeployMode string            `json:"deploy_mode"`
+	BlueGreen  *BlueGreenRequest `json:"blue_green"`
+	Handoff    *HandoffRequest   `json:"handoff"`
+	Systemd    *SystemdRequest   `json:"systemd"`
+
+	// 启用 releases/<version>/ 版本目录，KeepReleases 为保留的版本数
+	VersionedReleases bool   `json:"versioned_releases"`
+	ReleasesRoot      string `json:"releases_root"`
+	KeepReleases      int    `json:"keep_releases"`
+
+	ConfigRelease *ConfigReleaseRequest `json:"config_release"`
+
+	Notify *NotifyRequest `json:"notify"`
 }
 
-func startConfigWorkflow(c *gin.Context) {
-	var req WorkflowRequest
-	// Parse the request body
-	err := c.ShouldBindJSON(&req)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+// VersioningRequest 版本策略：timestamp、semver 或 explicit，Tag 为 true 时构建成功后推送附注标签
+type VersioningRequest struct {
+	Strategy string `json:"strategy"`
+	Prefix   string `json:"prefix"`
+	Initial  string `json:"initial"`
+	Tag      bool   `json:"tag"`
+}
+
+func (req *VersioningRequest) toSpec() pkg.VersioningSpec {
+	if req == nil {
+		return pkg.VersioningSpec{}
 	}
-	// Create Temporal client
-	temporalClient, err := client.Dial(client.Options{})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	return pkg.VersioningSpec{
+		Strategy: req.Strategy,
+		Prefix:   req.Prefix,
+		Initial:  req.Initial,
+		Tag:      req.Tag,
 	}
-	defer temporalClient.Close()
-	options := client.StartWorkflowOptions{
-		ID:        "config-workflow",
-		TaskQueue: "release-task-queue",
+}
+
+// NotifyRequest 工作流结束时的通知配置
+type NotifyRequest struct {
+	CommitStatus bool          `json:"commit_status"`
+	GitProvider  string        `json:"git_provider"`
+	GitAPIURL    string        `json:"git_api_url"`
+	TargetURL    string        `json:"target_url"`
+	Webhooks     []string      `json:"webhooks"`
+	Chats        []ChatRequest `json:"chats"`
+	Email        *EmailRequest `json:"email"`
+}
+
+// ChatRequest 聊天机器人 webhook，Template 为请求体模板
+type ChatRequest struct {
+	URL      string `json:"url"`
+	Template string `json:"template"`
+}
+
+// EmailRequest 邮件通知
+type EmailRequest struct {
+	To      []string `json:"to"`
+	Subject string   `json:"subject"`
+	Body    string   `json:"body"`
+}
+
+func (req *NotifyRequest) toSpec() pkg.NotifySpec {
+	if req == nil {
+		return pkg.NotifySpec{}
+	}
+	spec := pkg.NotifySpec{
+		CommitStatus: req.CommitStatus,
+		GitProvider:  req.GitProvider,
+		GitAPIURL:    req.GitAPIURL,
+		TargetURL:    req.TargetURL,
+		Webhooks:     req.Webhooks,
+	}
+	for _, chat := range req.Chats {
+		spec.Chats = append(spec.Chats, pkg.ChatTarget{URL: chat.URL, Template: chat.Template})
 	}
-	config := pkg.Config{
+	if req.Email != nil {
+		spec.Email = &pkg.EmailTarget{To: req.Email.To, Subject: req.Email.Subject, Body: req.Email.Body}
+	}
+	return spec
+}
+
+// ConfigReleaseRequest 配置发布参数
+type ConfigReleaseRequest struct {
+	Ref           string `json:"ref"`
+	Path          string `json:"path"`
+	SchemaPath    string `json:"schema_path"`
+	RemotePath    string `json:"remote_path"`
+	ReloadMode    string `json:"reload_mode"`
+	ReloadSignal  string `json:"reload_signal"`
+	PIDFile       string `json:"pid_file"`
+	ReloadCommand string `json:"reload_command"`
+}
+
+func (req *ConfigReleaseRequest) toSpec() pkg.ConfigReleaseSpec {
+	if req == nil {
+		return pkg.ConfigReleaseSpec{}
+	}
+	return pkg.ConfigReleaseSpec{
+		Ref:           req.Ref,
+		Path:          req.Path,
+		SchemaPath:    req.SchemaPath,
+		RemotePath:    req.RemotePath,
+		ReloadMode:    req.ReloadMode,
+		ReloadSignal:  req.ReloadSignal,
+		PIDFile:       req.PIDFile,
+		ReloadCommand: req.ReloadCommand,
+	}
+}
+
+// HealthCheckRequest 健康检查配置，时间字段单位为秒
+type HealthCheckRequest struct {
+	Type                string `json:"type"`
+	URL                 string `json:"url"`
+	Address             string `json:"address"`
+	GRPCService         string `json:"grpc_service"`
+	ExpectedStatusCodes []int  `json:"expected_status_codes"`
+	BodyContains        string `json:"body_contains"`
+	JSONPath            string `json:"json_path"`
+	JSONValue           string `json:"json_value"`
+	IntervalSeconds     int    `json:"interval_seconds"`
+	TimeoutSeconds      int    `json:"timeout_seconds"`
+	SuccessThreshold    int    `json:"success_threshold"`
+	DeadlineSeconds     int    `json:"deadline_seconds"`
+}
+
+// CanaryRequest 金丝雀发布配置，时间字段单位为秒
+type CanaryRequest struct {
+	Hosts             []string `json:"hosts"`
+	Count             int      `json:"count"`
+	MetricsURL        string   `json:"metrics_url"`
+	RequestsMetric    string   `json:"requests_metric"`
+	ErrorLabel        string   `json:"error_label"`
+	ErrorValuePrefix  string   `json:"error_value_prefix"`
+	LatencyMetric     string   `json:"latency_metric"`
+	WindowSeconds     int      `json:"window_seconds"`
+	MaxErrorRateDelta float64  `json:"max_error_rate_delta"`
+	MaxLatencyRatio   float64  `json:"max_latency_ratio"`
+	MinRequests       float64  `json:"min_requests"`
+	RollbackCommand   string   `json:"rollback_command"`
+}
+
+// BlueGreenRequest 蓝绿部署配置，时间字段单位为秒
+type BlueGreenRequest struct {
+	BlueDir             string `json:"blue_dir"`
+	BluePort            int    `json:"blue_port"`
+	GreenDir            string `json:"green_dir"`
+	GreenPort           int    `json:"green_port"`
+	StateFile           string `json:"state_file"`
+	StartCommand        string `json:"start_command"`
+	StopCommand         string `json:"stop_command"`
+	SwitchCommand       string `json:"switch_command"`
+	ProxyConfigFile     string `json:"proxy_config_file"`
+	ProxyConfigTemplate string `json:"proxy_config_template"`
+	ProxyReloadCommand  string `json:"proxy_reload_command"`
+	DrainSeconds        int    `json:"drain_seconds"`
+}
+
+// HandoffRequest 套接字移交重启配置，时间字段单位为秒
+type HandoffRequest struct {
+	PIDFile        string `json:"pid_file"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+}
+
+// SystemdRequest systemd 单元配置
+type SystemdRequest struct {
+	UnitName         string            `json:"unit_name"`
+	Description      string            `json:"description"`
+	ExecStart        string            `json:"exec_start"`
+	WorkingDirectory string            `json:"working_directory"`
+	User             string            `json:"user"`
+	Environment      map[string]string `json:"environment"`
+	Restart          string            `json:"restart"`
+	JournalLines     int               `json:"journal_lines"`
+	UseSudo          bool              `json:"use_sudo"`
+}
+
+func (req *SystemdRequest) toSpec() pkg.SystemdSpec {
+	if req == nil {
+		return pkg.SystemdSpec{}
+	}
+	return pkg.SystemdSpec{
+		UnitName:         req.UnitName,
+		Description:      req.Description,
+		ExecStart:        req.ExecStart,
+		WorkingDirectory: req.WorkingDirectory,
+		User:             req.User,
+		Environment:      req.Environment,
+		Restart:          req.Restart,
+		JournalLines:     req.JournalLines,
+		UseSudo:          req.UseSudo,
+	}
+}
+
+func (req *HandoffRequest) toSpec() pkg.HandoffSpec {
+	if req == nil {
+		return pkg.HandoffSpec{}
+	}
+	return pkg.HandoffSpec{
+		PIDFile: req.PIDFile,
+		Timeout: time.Duration(req.TimeoutSeconds) * time.Second,
+	}
+}
+
+func (req *BlueGreenRequest) toSpec() pkg.BlueGreenSpec {
+	if req == nil {
+		return pkg.BlueGreenSpec{}
+	}
+	return pkg.BlueGreenSpec{
+		Blue:                pkg.BlueGreenSlot{Dir: req.BlueDir, Port: req.BluePort},
+		Green:               pkg.BlueGreenSlot{Dir: req.GreenDir, Port: req.GreenPort},
+		StateFile:           req.StateFile,
+		StartCommand:        req.StartCommand,
+		StopCommand:         req.StopCommand,
+		SwitchCommand:       req.SwitchCommand,
+		ProxyConfigFile:     req.ProxyConfigFile,
+		ProxyConfigTemplate: req.ProxyConfigTemplate,
+		ProxyReloadCommand:  req.ProxyReloadCommand,
+		DrainPeriod:         time.Duration(req.DrainSeconds) * time.Second,
+	}
+}
+
+func (req *CanaryRequest) toSpec() pkg.CanarySpec {
+	if req == nil {
+		return pkg.CanarySpec{}
+	}
+	return pkg.CanarySpec{
+		Enabled:           true,
+		Hosts:             req.Hosts,
+		Count:             req.Count,
+		MetricsURL:        req.MetricsURL,
+		RequestsMetric:    req.RequestsMetric,
+		ErrorLabel:        req.ErrorLabel,
+		ErrorValuePrefix:  req.ErrorValuePrefix,
+		LatencyMetric:     req.LatencyMetric,
+		Window:            time.Duration(req.WindowSeconds) * time.Second,
+		MaxErrorRateDelta: req.MaxErrorRateDelta,
+		MaxLatencyRatio:   req.MaxLatencyRatio,
+		MinRequests:       req.MinRequests,
+		RollbackCommand:   req.RollbackCommand,
+	}
+}
+
+func (req *HealthCheckRequest) toSpec() pkg.HealthCheckSpec {
+	if req == nil {
+		return pkg.HealthCheckSpec{}
+	}
+	return pkg.HealthCheckSpec{
+		Type:                req.Type,
+		URL:                 req.URL,
+		Address:             req.Address,
+		GRPCService:         req.GRPCService,
+		ExpectedStatusCodes: req.ExpectedStatusCodes,
+		BodyContains:        req.BodyContains,
+		JSONPath:            req.JSONPath,
+		JSONValue:           req.JSONValue,
+		Interval:            time.Duration(req.IntervalSeconds) * time.Second,
+		Timeout:             time.Duration(req.TimeoutSeconds) * time.Second,
+		SuccessThreshold:    req.SuccessThreshold,
+		Deadline:            time.Duration(req.DeadlineSeconds) * time.Second,
+	}
+}
+
+// toConfig 将请求转换为工作流配置，并带上服务端配置的步骤选项
+func (req WorkflowRequest) toConfig() pkg.Config {
+	return pkg.Config{
 		RepoURL:        req.RepoURL,
 		Token:          req.Token,
 		BinaryPath:     req.BinaryPath,
 		ConfigFilePath: req.ConfigFilePath,
 		Version:        req.Version,
+		Versioning:     req.Versioning.toSpec(),
 		ECSUploadPath:  req.ECSUploadPath,
+		ECSServer:      req.ECSServer,
+		ECSUser:        req.ECSUser,
+		App:            req.App,
+		Environment:    req.Environment,
+		Actor:          req.Actor,
+		WaitForWindow:  req.WaitForWindow,
+		Override:       req.Override.toSpec(),
 		HealthCheckURL: req.HealthCheckURL,
+		HealthCheck:    req.HealthCheck.toSpec(),
+		Hosts:          req.Hosts,
+		Canary:         req.Canary.toSpec(),
+		DeployMode:     req.DeployMode,
+		BlueGreen:      req.BlueGreen.toSpec(),
+		Handoff:        req.Handoff.toSpec(),
+		Systemd:        req.Systemd.toSpec(),
+		Releases: pkg.ReleaseLayout{
+			Enabled: req.VersionedReleases,
+			Root:    req.ReleasesRoot,
+			Keep:    req.KeepReleases,
+		},
+		ConfigRelease: req.ConfigRelease.toSpec(),
+		Notify:        req.Notify.toSpec(),
+		Steps:         stepOptionsFromConfig(shared.Config.Activities),
+	}
+}
+
+// stepOptionsFromConfig 将配置文件中的活动配置转换为工作流步骤选项
+func stepOptionsFromConfig(activities map[string]shared.ActivityConfig) map[string]pkg.StepOptions {
+	steps := make(map[string]pkg.StepOptions, len(activities))
+	for step, ac := range activities {
+		opts := pkg.StepOptions{
+			StartToCloseTimeout: ac.StartToCloseTimeout,
+			HeartbeatTimeout:    ac.HeartbeatTimeout,
+		}
+		if ac.Retry != nil {
+			opts.Retry = &pkg.RetryOptions{
+				InitialInterval:        ac.Retry.InitialInterval,
+				BackoffCoefficient:     ac.Retry.BackoffCoefficient,
+				MaximumInterval:        ac.Retry.MaximumInterval,
+				MaximumAttempts:        ac.Retry.MaximumAttempts,
+				NonRetryableErrorTypes: ac.Retry.NonRetryableErrorTypes,
+			}
+		}
+		steps[step] = opts
 	}
+	return steps
+}
+
+func startConfigWorkflow(c *gin.Context) {
+	var req WorkflowRequest
+	// Parse the request body
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	temporalClient := getTemporalClient(c)
+	options := client.StartWorkflowOptions{
+		ID:        "config-workflow",
+		TaskQueue: shared.Config.Temporal.TaskQueue,
+	}
+	config := req.toConfig()
 	we, err := temporalClient.ExecuteWorkflow(context.Background(), options, pkg.ConfigWorkflow, config)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -82,26 +403,12 @@ func startBuildUploadWorkflow(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	// Create Temporal client
-	temporalClient, err := client.Dial(client.Options{})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	defer temporalClient.Close()
+	temporalClient := getTemporalClient(c)
 	options := client.StartWorkflowOptions{
 		ID:        "build-upload-workflow",
-		TaskQueue: "release-task-queue",
-	}
-	config := pkg.Config{
-		RepoURL:        req.RepoURL,
-		Token:          req.Token,
-		BinaryPath:     req.BinaryPath,
-		ConfigFilePath: req.ConfigFilePath,
-		Version:        req.Version,
-		ECSUploadPath:  req.ECSUploadPath,
-		HealthCheckURL: req.HealthCheckURL,
+		TaskQueue: shared.Config.Temporal.TaskQueue,
 	}
+	config := req.toConfig()
 	we, err := temporalClient.ExecuteWorkflow(context.Background(), options, pkg.BuildUploadWorkflow, config)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -122,27 +429,57 @@ func startReleaseWorkflow(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	// Create Temporal client
-	temporalClient, err := client.Dial(client.Options{})
+	config := req.toConfig()
+	if !checkPolicy(c, config) {
+		return
+	}
+	// 等待发布窗口的发布可能持续数天，按应用、环境和版本区分，避免阻塞其它发布
+	temporalClient := getTemporalClient(c)
+	options := client.StartWorkflowOptions{
+		ID:        workflowID("release", config.AppName(), config.Environment, config.Version),
+		TaskQueue: shared.Config.Temporal.TaskQueue,
+	}
+	we, err := temporalClient.ExecuteWorkflow(context.Background(), options, pkg.ReleaseWorkflow, config)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer temporalClient.Close()
-	options := client.StartWorkflowOptions{
-		ID:        "release-workflow",
-		TaskQueue: "release-task-queue",
+	c.JSON(http.StatusOK, gin.H{
+		"workflow_id": we.GetID(),
+		"run_id":      we.GetRunID(),
+	})
+}
+
+// workflowID 用前缀和非空的应用、环境、版本拼出工作流 ID
+func workflowID(prefix string, parts ...string) string {
+	id := prefix
+	for _, part := range parts {
+		if part != "" {
+			id += "-" + part
+		}
 	}
-	config := pkg.Config{
-		RepoURL:        req.RepoURL,
-		Token:          req.Token,
-		BinaryPath:     req.BinaryPath,
-		ConfigFilePath: req.ConfigFilePath,
-		Version:        req.Version,
-		ECSUploadPath:  req.ECSUploadPath,
-		HealthCheckURL: req.HealthCheckURL,
+	return id
+}
+
+// Handler for starting config-only release workflow
+func startConfigReleaseWorkflow(c *gin.Context) {
+	var req WorkflowRequest
+	// Parse the request body
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	we, err := temporalClient.ExecuteWorkflow(context.Background(), options, pkg.ReleaseWorkflow, config)
+	config := req.toConfig()
+	if !checkPolicy(c, config) {
+		return
+	}
+	temporalClient := getTemporalClient(c)
+	options := client.StartWorkflowOptions{
+		ID:        workflowID("config-release", config.AppName(), config.Environment, config.ConfigRelease.Ref),
+		TaskQueue: shared.Config.Temporal.TaskQueue,
+	}
+	we, err := temporalClient.ExecuteWorkflow(context.Background(), options, pkg.ConfigReleaseWorkflow, config)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -158,13 +495,69 @@ func main() {
 	shared.InitConfig()
 	shared.InitLogger()
 	shared.InitDatabase()
+	if err := pkg.Migrate(shared.GetDB()); err != nil {
+		shared.Logger.Fatalf("migrate database error: %v", err)
+	}
+	if shared.Config.Log.ActivityDir != "" {
+		pkg.ActivityLogs.Dir = shared.Config.Log.ActivityDir
+	}
+	if shared.Config.Log.ActivityMaxBytes > 0 {
+		pkg.ActivityLogs.MaxBytes = shared.Config.Log.ActivityMaxBytes
+	}
+
+	// 进程内共用的 Temporal 客户端，由需要它的路由通过中间件注入
+	temporalConn := newTemporalConn(shared.NewTemporalClient)
+	defer temporalConn.Close()
+	withTemporal := requireTemporal(temporalConn)
 
 	// 设置 Gin 路由
 	r := gin.Default()
 
-	r.POST("/api/start-config", startConfigWorkflow)
-	r.POST("/api/start-build-upload", startBuildUploadWorkflow)
-	r.POST("/api/start-release", startReleaseWorkflow)
+	r.POST("/api/start-config", withTemporal, startConfigWorkflow)
+	r.POST("/api/start-build-upload", withTemporal, startBuildUploadWorkflow)
+	r.POST("/api/start-release", withTemporal, startReleaseWorkflow)
+	r.POST("/api/start-config-release", withTemporal, startConfigReleaseWorkflow)
+	r.GET("/api/workflows/:id/events", withTemporal, streamWorkflowEvents)
+	r.GET("/api/workflows/:id/runs/:run_id/logs", listActivityLogs)
+	r.GET("/api/workflows/:id/runs/:run_id/logs/:activity", getActivityLog)
+	r.GET("/api/apps/:app/environments/:env/variables", listEnvironmentVariables)
+	r.PUT("/api/apps/:app/environments/:env/variables/:key", putEnvironmentVariable)
+	r.DELETE("/api/apps/:app/environments/:env/variables/:key", deleteEnvironmentVariable)
+	r.GET("/api/apps/:app/environments/:env/rendered-configs", listRenderedConfigs)
+	r.GET("/api/rendered-configs/:id", getRenderedConfig)
+	r.GET("/api/apps/:app/environments", listEnvironments)
+	r.PUT("/api/apps/:app/environments/:env", putEnvironment)
+	r.DELETE("/api/apps/:app/environments/:env", deleteEnvironment)
+	r.GET("/api/apps/:app/environments/:env/releases", withTemporal, listHostReleases)
+	r.POST("/api/apps/:app/promote", withTemporal, promoteRelease)
+	r.GET("/api/apps/:app/environments/:env/policy", getPolicy)
+	r.POST("/api/apps/:app/environments/:env/policy/windows", requireAdmin, createDeployWindow)
+	r.DELETE("/api/apps/:app/environments/:env/policy/windows/:id", requireAdmin, deletePolicyItem(&pkg.DeployWindow{}))
+	r.POST("/api/apps/:app/environments/:env/policy/freezes", requireAdmin, createFreezePeriod)
+	r.DELETE("/api/apps/:app/environments/:env/policy/freezes/:id", requireAdmin, deletePolicyItem(&pkg.FreezePeriod{}))
+	r.GET("/api/apps/:app/environments/:env/policy/overrides", listPolicyOverrides)
+	r.GET("/api/apps", listApplications)
+	r.GET("/api/apps/:app", getApplication)
+	r.PUT("/api/apps/:app", putApplication)
+	r.DELETE("/api/apps/:app", deleteApplication)
+	r.POST("/api/webhooks/git", withTemporal, receiveGitWebhook)
+	r.POST("/api/subscriptions", createSubscription)
+	r.GET("/api/subscriptions", listSubscriptions)
+	r.GET("/api/subscriptions/:id", getSubscription)
+	r.PUT("/api/subscriptions/:id", updateSubscription)
+	r.DELETE("/api/subscriptions/:id", deleteSubscription)
+	r.GET("/api/subscriptions/:id/deliveries", listDeliveries)
+	r.POST("/api/schedules", withTemporal, createSchedule)
+	r.GET("/api/schedules", withTemporal, listSchedules)
+	r.GET("/api/schedules/:id", withTemporal, describeSchedule)
+	r.POST("/api/schedules/:id/pause", withTemporal, setSchedulePaused(true))
+	r.POST("/api/schedules/:id/unpause", withTemporal, setSchedulePaused(false))
+	r.DELETE("/api/schedules/:id", withTemporal, deleteSchedule)
+	r.GET("/api/releases", listReleases)
+	r.GET("/api/releases/:id", getRelease)
+	r.GET("/api/releases/:id/diff/:other", diffReleases)
+	r.GET("/api/inventory", getInventory)
+	r.GET("/api/inventory/hosts/:host/history", getHostHistory)
 
 	// 创建健康检查实例
 	health := gosundheit.New()
@@ -190,6 +583,15 @@ func main() {
 		shared.Logger.Infof("Failed to register check: %v", err)
 	}
 
+	// Temporal 连接状态
+	err = health.RegisterCheck(
+		&checks.CustomCheck{CheckName: "temporal", CheckFunc: temporalConn.Status},
+		gosundheit.ExecutionPeriod(10*time.Second),
+	)
+	if err != nil {
+		shared.Logger.Infof("Failed to register temporal check: %v", err)
+	}
+
 	// 注册健康检查端点
 	r.GET("/admin/health.json", gin.WrapH(healthhttp.HandleHealthJSON(health)))
 
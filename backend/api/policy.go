@@ -0,0 +1,198 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"temporal-aone/backend/pkg"
+	"temporal-aone/backend/shared"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminTokenHeader 管理员令牌请求头
+const AdminTokenHeader = "X-Admin-Token"
+
+// OverrideRequest 绕过发布策略的原因，Actor 为操作人
+type OverrideRequest struct {
+	Actor  string `json:"actor"`
+	Reason string `json:"reason"`
+}
+
+func (req *OverrideRequest) toSpec() *pkg.OverrideSpec {
+	if req == nil {
+		return nil
+	}
+	return &pkg.OverrideSpec{Actor: req.Actor, Reason: req.Reason}
+}
+
+// DeployWindowRequest 发布窗口
+type DeployWindowRequest struct {
+	Cron     string `json:"cron" binding:"required"`
+	Timezone string `json:"timezone"`
+}
+
+// FreezePeriodRequest 封版期
+type FreezePeriodRequest struct {
+	Start  time.Time `json:"start" binding:"required"`
+	End    time.Time `json:"end" binding:"required"`
+	Reason string    `json:"reason"`
+}
+
+func isAdmin(c *gin.Context) bool {
+	token := shared.Config.Server.AdminToken
+	given := c.GetHeader(AdminTokenHeader)
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(given)) == 1
+}
+
+// requireAdmin rejects requests without a valid admin token
+func requireAdmin(c *gin.Context) {
+	if !isAdmin(c) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin token required"})
+		return
+	}
+	c.Next()
+}
+
+// checkPolicy rejects a release outside the deployment windows of its
+// environment unless it is allowed to wait or an admin overrides the policy;
+// the workflow checks again when it starts and audits the override
+func checkPolicy(c *gin.Context, config pkg.Config) bool {
+	if config.Environment == "" {
+		return true
+	}
+	if config.Override != nil {
+		if !isAdmin(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "admin token required to override release policy"})
+			return false
+		}
+		if config.Override.Actor == "" || config.Override.Reason == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "override requires actor and reason"})
+			return false
+		}
+		return true
+	}
+	if config.WaitForWindow {
+		return true
+	}
+
+	policy, err := pkg.LoadPolicy(config.AppName(), config.Environment)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	decision, err := policy.Evaluate(time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if !decision.Allowed {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":        "release blocked by policy: " + decision.Reason,
+			"next_allowed": decision.NextAllowed,
+		})
+		return false
+	}
+	return true
+}
+
+// Handler for showing the deployment windows and freezes of an environment
+// together with whether a release is allowed right now
+func getPolicy(c *gin.Context) {
+	policy, err := pkg.LoadPolicy(c.Param("app"), c.Param("env"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	decision, err := policy.Evaluate(time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"windows":  policy.Windows,
+		"freezes":  policy.Freezes,
+		"decision": decision,
+	})
+}
+
+// Handler for adding a deployment window
+func createDeployWindow(c *gin.Context) {
+	var req DeployWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	window := pkg.DeployWindow{
+		App:         c.Param("app"),
+		Environment: c.Param("env"),
+		Cron:        req.Cron,
+		Timezone:    req.Timezone,
+	}
+	if _, err := (pkg.DeploymentPolicy{Windows: []pkg.DeployWindow{window}}).Evaluate(time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := shared.GetDB().Create(&window).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, window)
+}
+
+// Handler for adding a freeze period
+func createFreezePeriod(c *gin.Context) {
+	var req FreezePeriodRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.End.After(req.Start) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end must be after start"})
+		return
+	}
+	freeze := pkg.FreezePeriod{
+		App:         c.Param("app"),
+		Environment: c.Param("env"),
+		Start:       req.Start,
+		End:         req.End,
+		Reason:      req.Reason,
+	}
+	if err := shared.GetDB().Create(&freeze).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, freeze)
+}
+
+// deletePolicyItem returns a handler deleting a window or freeze of the environment by id
+func deletePolicyItem(model interface{}) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := shared.GetDB().
+			Where("id = ? AND app = ? AND environment = ?", c.Param("id"), c.Param("app"), c.Param("env")).
+			Delete(model)
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// Handler for the audit log of policy overrides, newest first
+func listPolicyOverrides(c *gin.Context) {
+	var overrides []pkg.PolicyOverride
+	err := shared.GetDB().
+		Where("app = ? AND environment = ?", c.Param("app"), c.Param("env")).
+		Order("id desc").
+		Find(&overrides).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"overrides": overrides})
+}
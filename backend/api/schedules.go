@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"temporal-aone/backend/pkg"
+	"temporal-aone/backend/shared"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	commonpb "go.temporal.io/api/common/v1"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+)
+
+// 定时任务可以启动的工作流
+var scheduleWorkflows = map[string]interface{}{
+	"build-upload": pkg.BuildUploadWorkflow,
+	"release":      pkg.ReleaseWorkflow,
+}
+
+// 并发策略：上一次运行未结束时如何处理新的触发
+var overlapPolicies = map[string]enumspb.ScheduleOverlapPolicy{
+	"":                enumspb.SCHEDULE_OVERLAP_POLICY_SKIP,
+	"skip":            enumspb.SCHEDULE_OVERLAP_POLICY_SKIP,
+	"buffer_one":      enumspb.SCHEDULE_OVERLAP_POLICY_BUFFER_ONE,
+	"buffer_all":      enumspb.SCHEDULE_OVERLAP_POLICY_BUFFER_ALL,
+	"cancel_other":    enumspb.SCHEDULE_OVERLAP_POLICY_CANCEL_OTHER,
+	"terminate_other": enumspb.SCHEDULE_OVERLAP_POLICY_TERMINATE_OTHER,
+	"allow_all":       enumspb.SCHEDULE_OVERLAP_POLICY_ALLOW_ALL,
+}
+
+// ScheduleRequest 创建定时发布的请求，Config 为每次触发时使用的工作流参数
+type ScheduleRequest struct {
+	ID       string   `json:"id" binding:"required"`
+	Workflow string   `json:"workflow" binding:"required"`
+	Cron     []string `json:"cron" binding:"required,min=1"`
+	Timezone string   `json:"timezone"`
+	Overlap  string   `json:"overlap"`
+	// 超过该时间未能触发的运行不再补跑，单位为秒
+	CatchupWindowSeconds int    `json:"catchup_window_seconds"`
+	PauseOnFailure       bool   `json:"pause_on_failure"`
+	Paused               bool   `json:"paused"`
+	Note                 string `json:"note"`
+
+	Config WorkflowRequest `json:"config"`
+}
+
+// SchedulePauseRequest 暂停或恢复定时任务的备注
+type SchedulePauseRequest struct {
+	Note string `json:"note"`
+}
+
+// ScheduleSummary 定时任务概要
+type ScheduleSummary struct {
+	ID              string      `json:"id"`
+	App             string      `json:"app"`
+	Workflow        string      `json:"workflow"`
+	Cron            []string    `json:"cron"`
+	Timezone        string      `json:"timezone"`
+	Paused          bool        `json:"paused"`
+	Note            string      `json:"note"`
+	NextActionTimes []time.Time `json:"next_action_times"`
+}
+
+// respondScheduleError maps schedule client errors to HTTP responses
+func respondScheduleError(c *gin.Context, err error) {
+	switch err.(type) {
+	case *serviceerror.NotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case *serviceerror.AlreadyExists, *serviceerror.WorkflowExecutionAlreadyStarted:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// memoString 读取 memo 中的字符串字段
+func memoString(fields map[string]*commonpb.Payload, key string) string {
+	payload, ok := fields[key]
+	if !ok {
+		return ""
+	}
+	var value string
+	if err := converter.GetDefaultDataConverter().FromPayload(payload, &value); err != nil {
+		return ""
+	}
+	return value
+}
+
+// memoStrings 读取 memo 中的字符串列表字段
+func memoStrings(fields map[string]*commonpb.Payload, key string) []string {
+	payload, ok := fields[key]
+	if !ok {
+		return nil
+	}
+	var values []string
+	if err := converter.GetDefaultDataConverter().FromPayload(payload, &values); err != nil {
+		return nil
+	}
+	return values
+}
+
+// Handler for creating a schedule that starts a build or release workflow on a cron expression
+func createSchedule(c *gin.Context) {
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	workflow, ok := scheduleWorkflows[req.Workflow]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "workflow must be build-upload or release"})
+		return
+	}
+	overlap, ok := overlapPolicies[strings.ToLower(req.Overlap)]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown overlap policy " + req.Overlap})
+		return
+	}
+	config := req.Config.toConfig()
+	if config.Override != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scheduled releases cannot override release policy"})
+		return
+	}
+	if config.Actor == "" {
+		config.Actor = "schedule:" + req.ID
+	}
+
+	temporalClient := getTemporalClient(c)
+
+	handle, err := temporalClient.ScheduleClient().Create(context.Background(), client.ScheduleOptions{
+		ID: req.ID,
+		Spec: client.ScheduleSpec{
+			CronExpressions: req.Cron,
+			TimeZoneName:    req.Timezone,
+		},
+		Action: &client.ScheduleWorkflowAction{
+			ID:        "scheduled-" + req.ID,
+			Workflow:  workflow,
+			Args:      []interface{}{config},
+			TaskQueue: shared.Config.Temporal.TaskQueue,
+		},
+		Overlap:        overlap,
+		CatchupWindow:  time.Duration(req.CatchupWindowSeconds) * time.Second,
+		PauseOnFailure: req.PauseOnFailure,
+		Paused:         req.Paused,
+		Note:           req.Note,
+		// 服务端会把 cron 表达式转换成 calendar，列表中拿不到原始表达式，保存在 memo 中
+		Memo: map[string]interface{}{
+			"app":      config.AppName(),
+			"workflow": req.Workflow,
+			"cron":     req.Cron,
+		},
+	})
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"schedule_id": handle.GetID()})
+}
+
+// Handler for listing schedules, optionally filtered by ?app=
+func listSchedules(c *gin.Context) {
+	temporalClient := getTemporalClient(c)
+
+	iter, err := temporalClient.ScheduleClient().List(context.Background(), client.ScheduleListOptions{PageSize: 100})
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+	app := c.Query("app")
+	schedules := []ScheduleSummary{}
+	for iter.HasNext() {
+		entry, err := iter.Next()
+		if err != nil {
+			respondScheduleError(c, err)
+			return
+		}
+		summary := ScheduleSummary{
+			ID:              entry.ID,
+			Workflow:        entry.WorkflowType.Name,
+			Paused:          entry.Paused,
+			Note:            entry.Note,
+			NextActionTimes: entry.NextActionTimes,
+		}
+		if entry.Memo != nil {
+			summary.App = memoString(entry.Memo.GetFields(), "app")
+			if wf := memoString(entry.Memo.GetFields(), "workflow"); wf != "" {
+				summary.Workflow = wf
+			}
+			summary.Cron = memoStrings(entry.Memo.GetFields(), "cron")
+		}
+		if entry.Spec != nil {
+			summary.Timezone = entry.Spec.TimeZoneName
+		}
+		if app != "" && summary.App != app {
+			continue
+		}
+		schedules = append(schedules, summary)
+	}
+	c.JSON(http.StatusOK, gin.H{"schedules": schedules})
+}
+
+// Handler for describing one schedule with its recent and upcoming runs
+func describeSchedule(c *gin.Context) {
+	temporalClient := getTemporalClient(c)
+
+	desc, err := temporalClient.ScheduleClient().GetHandle(context.Background(), c.Param("id")).Describe(context.Background())
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+	response := gin.H{
+		"id":                c.Param("id"),
+		"paused":            desc.Schedule.State.Paused,
+		"note":              desc.Schedule.State.Note,
+		"num_actions":       desc.Info.NumActions,
+		"skipped_overlap":   desc.Info.NumActionsSkippedOverlap,
+		"running_workflows": desc.Info.RunningWorkflows,
+		"recent_actions":    desc.Info.RecentActions,
+		"next_action_times": desc.Info.NextActionTimes,
+	}
+	if desc.Memo != nil {
+		response["cron"] = memoStrings(desc.Memo.GetFields(), "cron")
+	}
+	if desc.Schedule.Spec != nil {
+		response["calendars"] = desc.Schedule.Spec.Calendars
+		response["timezone"] = desc.Schedule.Spec.TimeZoneName
+	}
+	if desc.Schedule.Policy != nil {
+		response["overlap"] = desc.Schedule.Policy.Overlap.String()
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Handler for pausing (pause=true) or unpausing a schedule
+func setSchedulePaused(pause bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SchedulePauseRequest
+		// 备注是可选的
+		_ = c.ShouldBindJSON(&req)
+
+		temporalClient := getTemporalClient(c)
+
+		handle := temporalClient.ScheduleClient().GetHandle(context.Background(), c.Param("id"))
+		var err error
+		if pause {
+			err = handle.Pause(context.Background(), client.SchedulePauseOptions{Note: req.Note})
+		} else {
+			err = handle.Unpause(context.Background(), client.ScheduleUnpauseOptions{Note: req.Note})
+		}
+		if err != nil {
+			respondScheduleError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"schedule_id": c.Param("id"), "paused": pause})
+	}
+}
+
+// Handler for deleting a schedule; running workflows it started are not affected
+func deleteSchedule(c *gin.Context) {
+	temporalClient := getTemporalClient(c)
+
+	if err := temporalClient.ScheduleClient().GetHandle(context.Background(), c.Param("id")).Delete(context.Background()); err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+	"temporal-aone/backend/pkg"
+	"temporal-aone/backend/shared"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SubscriptionRequest 事件订阅，Active 为空时默认启用
+type SubscriptionRequest struct {
+	URL         string   `json:"url" binding:"required,url"`
+	Secret      string   `json:"secret"`
+	Events      []string `json:"events" binding:"required,min=1"`
+	App         string   `json:"app"`
+	Description string   `json:"description"`
+	Active      *bool    `json:"active"`
+}
+
+func (req SubscriptionRequest) validate() error {
+	for _, pattern := range req.Events {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.New("invalid event pattern " + pattern)
+		}
+	}
+	return nil
+}
+
+func findSubscription(c *gin.Context) (pkg.Subscription, bool) {
+	var sub pkg.Subscription
+	err := shared.GetDB().First(&sub, c.Param("id")).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+		return sub, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return sub, false
+	}
+	return sub, true
+}
+
+// Handler for registering a webhook subscription
+func createSubscription(c *gin.Context) {
+	var req SubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sub := pkg.Subscription{
+		URL:         req.URL,
+		Secret:      req.Secret,
+		Events:      req.Events,
+		App:         req.App,
+		Description: req.Description,
+		Active:      req.Active == nil || *req.Active,
+	}
+	if err := shared.GetDB().Create(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+// Handler for listing subscriptions
+func listSubscriptions(c *gin.Context) {
+	var subs []pkg.Subscription
+	if err := shared.GetDB().Order("id").Find(&subs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"subscriptions": subs})
+}
+
+// Handler for fetching one subscription
+func getSubscription(c *gin.Context) {
+	if sub, ok := findSubscription(c); ok {
+		c.JSON(http.StatusOK, sub)
+	}
+}
+
+// Handler for updating a subscription; an empty secret keeps the current one
+func updateSubscription(c *gin.Context) {
+	sub, ok := findSubscription(c)
+	if !ok {
+		return
+	}
+	var req SubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sub.URL = req.URL
+	sub.Events = req.Events
+	sub.App = req.App
+	sub.Description = req.Description
+	if req.Secret != "" {
+		sub.Secret = req.Secret
+	}
+	if req.Active != nil {
+		sub.Active = *req.Active
+	}
+	if err := shared.GetDB().Save(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+// Handler for deleting a subscription; its delivery log is kept
+func deleteSubscription(c *gin.Context) {
+	result := shared.GetDB().Delete(&pkg.Subscription{}, c.Param("id"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Handler for the delivery log of a subscription, newest first;
+// ?success=false shows failed attempts only
+func listDeliveries(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+		return
+	}
+	query := shared.GetDB().Where("subscription_id = ?", c.Param("id"))
+	if success := c.Query("success"); success != "" {
+		ok, err := strconv.ParseBool(success)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "success must be true or false"})
+			return
+		}
+		query = query.Where("success = ?", ok)
+	}
+	var deliveries []pkg.Delivery
+	if err := query.Order("id desc").Limit(limit).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
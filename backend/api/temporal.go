@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"temporal-aone/backend/shared"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.temporal.io/sdk/client"
+)
+
+// gin 上下文中保存 Temporal 客户端和连接的键
+const (
+	temporalClientKey = "temporal_client"
+	temporalConnKey   = "temporal_conn"
+)
+
+const (
+	// 连接正常时的健康检查间隔
+	temporalCheckInterval = 10 * time.Second
+	temporalCheckTimeout  = 5 * time.Second
+	// 连接异常时的重试退避
+	temporalMinBackoff = time.Second
+	temporalMaxBackoff = 30 * time.Second
+	// 连续失败达到次数后重新建立客户端，之前的失败先等 gRPC 自身重连
+	temporalRedialAfter = 3
+	// 重连后旧客户端保留的时间，让仍在使用它的请求完成
+	temporalCloseGrace = 5 * time.Minute
+)
+
+var errTemporalUnavailable = errors.New("temporal is unavailable")
+
+// TemporalConn API 进程共用的 Temporal 客户端，后台定期检查连接，异常时按指数退避重试和重连
+type TemporalConn struct {
+	dial func() (client.Client, error)
+	// 检查间隔、退避和旧客户端的保留时间，测试中可以缩短
+	checkInterval time.Duration
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+	closeGrace    time.Duration
+
+	mu          sync.RWMutex
+	client      client.Client
+	healthy     bool
+	lastErr     error
+	connectedAt time.Time
+	reconnects  int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newTemporalConn 尝试建立连接并在后台维护，首次连接失败不阻塞启动
+func newTemporalConn(dial func() (client.Client, error)) *TemporalConn {
+	t := &TemporalConn{
+		dial:          dial,
+		checkInterval: temporalCheckInterval,
+		minBackoff:    temporalMinBackoff,
+		maxBackoff:    temporalMaxBackoff,
+		closeGrace:    temporalCloseGrace,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	t.redial()
+	go t.run()
+	return t
+}
+
+// Client 返回当前可用的客户端，连接异常时返回 errTemporalUnavailable
+func (t *TemporalConn) Client() (client.Client, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.client == nil || !t.healthy {
+		if t.lastErr != nil {
+			return nil, fmt.Errorf("%w: %v", errTemporalUnavailable, t.lastErr)
+		}
+		return nil, errTemporalUnavailable
+	}
+	return t.client, nil
+}
+
+// redial 建立新客户端，成功后替换旧客户端；SSE 等请求可能仍在使用旧客户端，宽限期后再关闭
+func (t *TemporalConn) redial() bool {
+	c, err := t.dial()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil {
+		t.healthy, t.lastErr = false, err
+		shared.Logger.Warnf("connect to temporal failed: %v", err)
+		return false
+	}
+	old := t.client
+	if old != nil {
+		t.reconnects++
+	}
+	t.client, t.healthy, t.lastErr, t.connectedAt = c, true, nil, time.Now()
+	if old != nil {
+		time.AfterFunc(t.closeGrace, old.Close)
+	}
+	shared.Logger.Infof("connected to temporal at %s", shared.Config.Temporal.HostPort)
+	return true
+}
+
+// check 对当前客户端做一次健康检查，还没有客户端时返回 false
+func (t *TemporalConn) check() bool {
+	t.mu.RLock()
+	c := t.client
+	t.mu.RUnlock()
+	if c == nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), temporalCheckTimeout)
+	defer cancel()
+	_, err := c.CheckHealth(ctx, &client.CheckHealthRequest{})
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil {
+		if t.healthy {
+			shared.Logger.Warnf("temporal health check failed: %v", err)
+		}
+		t.healthy, t.lastErr = false, err
+		return false
+	}
+	if !t.healthy {
+		shared.Logger.Infof("temporal connection recovered")
+	}
+	t.healthy, t.lastErr = true, nil
+	return true
+}
+
+func (t *TemporalConn) connected() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.client != nil
+}
+
+// run 连接正常时定期检查，异常时按退避重试；没有客户端或连续失败多次后重新建立客户端
+func (t *TemporalConn) run() {
+	defer close(t.done)
+	backoff := t.minBackoff
+	failures := 0
+	if !t.connected() {
+		failures = temporalRedialAfter
+	}
+	for {
+		wait := t.checkInterval
+		if failures > 0 {
+			wait = backoff
+		}
+		select {
+		case <-t.stop:
+			return
+		case <-time.After(wait):
+		}
+
+		ok := t.check()
+		if !ok && (failures+1 >= temporalRedialAfter || !t.connected()) {
+			ok = t.redial()
+		}
+		if ok {
+			failures, backoff = 0, t.minBackoff
+			continue
+		}
+		failures++
+		if failures > 1 {
+			backoff *= 2
+			if backoff > t.maxBackoff {
+				backoff = t.maxBackoff
+			}
+		}
+	}
+}
+
+// Status 健康检查端点展示的连接状态，连接异常时返回错误
+func (t *TemporalConn) Status(ctx context.Context) (interface{}, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	details := gin.H{
+		"host_port":  shared.Config.Temporal.HostPort,
+		"namespace":  shared.Config.Temporal.Namespace,
+		"healthy":    t.healthy,
+		"reconnects": t.reconnects,
+	}
+	if !t.connectedAt.IsZero() {
+		details["connected_at"] = t.connectedAt
+	}
+	if t.client == nil || !t.healthy {
+		err := t.lastErr
+		if err == nil {
+			err = errTemporalUnavailable
+		}
+		return details, err
+	}
+	return details, nil
+}
+
+// Close 停止后台检查并关闭客户端
+func (t *TemporalConn) Close() {
+	close(t.stop)
+	<-t.done
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client != nil {
+		t.client.Close()
+		t.client = nil
+	}
+}
+
+// requireTemporal 为需要 Temporal 的路由注入共用客户端，连接不可用时返回 503
+func requireTemporal(conn *TemporalConn) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		temporalClient, err := conn.Client()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		c.Set(temporalClientKey, temporalClient)
+		c.Set(temporalConnKey, conn)
+		c.Next()
+	}
+}
+
+// getTemporalClient 返回 requireTemporal 注入的客户端
+func getTemporalClient(c *gin.Context) client.Client {
+	return c.MustGet(temporalClientKey).(client.Client)
+}
+
+// getTemporalConn 返回共用连接，长连接的请求每次使用前从连接取客户端，重连后使用新客户端
+func getTemporalConn(c *gin.Context) *TemporalConn {
+	return c.MustGet(temporalConnKey).(*TemporalConn)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/client"
+)
+
+// fakeTemporalClient 只实现连接维护用到的方法
+type fakeTemporalClient struct {
+	client.Client
+
+	mu        sync.Mutex
+	healthErr error
+	closed    bool
+}
+
+func (f *fakeTemporalClient) CheckHealth(ctx context.Context, req *client.CheckHealthRequest) (*client.CheckHealthResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.healthErr != nil {
+		return nil, f.healthErr
+	}
+	return &client.CheckHealthResponse{}, nil
+}
+
+func (f *fakeTemporalClient) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+}
+
+func (f *fakeTemporalClient) setHealth(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.healthErr = err
+}
+
+func (f *fakeTemporalClient) isClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+// fakeDial 按顺序返回预设的结果，用完后一直返回错误
+type fakeDial struct {
+	mu      sync.Mutex
+	results []interface{}
+	calls   int
+}
+
+func (d *fakeDial) dial() (client.Client, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls++
+	if len(d.results) == 0 {
+		return nil, errors.New("dial refused")
+	}
+	result := d.results[0]
+	d.results = d.results[1:]
+	if err, ok := result.(error); ok {
+		return nil, err
+	}
+	return result.(client.Client), nil
+}
+
+func (d *fakeDial) callCount() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.calls
+}
+
+func newTestTemporalConn(dial func() (client.Client, error)) *TemporalConn {
+	return &TemporalConn{
+		dial:          dial,
+		checkInterval: time.Hour,
+		minBackoff:    time.Millisecond,
+		maxBackoff:    4 * time.Millisecond,
+		closeGrace:    20 * time.Millisecond,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTemporalConnRedial(t *testing.T) {
+	first := &fakeTemporalClient{}
+	second := &fakeTemporalClient{}
+	d := &fakeDial{results: []interface{}{errors.New("connection refused"), first, second}}
+	conn := newTestTemporalConn(d.dial)
+
+	if conn.redial() {
+		t.Fatal("redial succeeded with a failing dial")
+	}
+	if _, err := conn.Client(); !errors.Is(err, errTemporalUnavailable) {
+		t.Fatalf("Client() error = %v, want errTemporalUnavailable", err)
+	}
+	if conn.check() {
+		t.Fatal("check succeeded without a client")
+	}
+
+	if !conn.redial() {
+		t.Fatal("redial failed")
+	}
+	if c, err := conn.Client(); err != nil || c != first {
+		t.Fatalf("Client() = %v, %v, want first client", c, err)
+	}
+
+	first.setHealth(errors.New("unavailable"))
+	if conn.check() {
+		t.Fatal("check succeeded with a failing health check")
+	}
+	if _, err := conn.Client(); !errors.Is(err, errTemporalUnavailable) {
+		t.Fatalf("Client() error = %v, want errTemporalUnavailable", err)
+	}
+	first.setHealth(nil)
+	if !conn.check() {
+		t.Fatal("check failed after recovery")
+	}
+
+	if !conn.redial() {
+		t.Fatal("second redial failed")
+	}
+	if c, _ := conn.Client(); c != second {
+		t.Fatal("Client() did not return the new client")
+	}
+	if conn.reconnects != 1 {
+		t.Errorf("reconnects = %d, want 1", conn.reconnects)
+	}
+	// 旧客户端可能仍被 SSE 等请求使用，宽限期后才关闭
+	if first.isClosed() {
+		t.Fatal("old client closed immediately after redial")
+	}
+	waitFor(t, "old client to close", first.isClosed)
+	if second.isClosed() {
+		t.Fatal("current client closed")
+	}
+}
+
+func TestTemporalConnRunBackoff(t *testing.T) {
+	first := &fakeTemporalClient{}
+	second := &fakeTemporalClient{}
+	d := &fakeDial{results: []interface{}{errors.New("connection refused"), errors.New("connection refused"), first, second}}
+	conn := newTestTemporalConn(d.dial)
+	conn.checkInterval = time.Millisecond
+
+	// 首次连接失败不阻塞，run 按退避重试直到连接成功
+	conn.redial()
+	go conn.run()
+	defer conn.Close()
+	waitFor(t, "initial connection", func() bool {
+		c, err := conn.Client()
+		return err == nil && c == first
+	})
+	if calls := d.callCount(); calls != 3 {
+		t.Errorf("dial calls = %d, want 3", calls)
+	}
+
+	// 健康检查连续失败 temporalRedialAfter 次后才重新建立客户端
+	first.setHealth(errors.New("unavailable"))
+	waitFor(t, "redial after failed checks", func() bool {
+		c, err := conn.Client()
+		return err == nil && c == second
+	})
+	if calls := d.callCount(); calls != 4 {
+		t.Errorf("dial calls = %d, want 4", calls)
+	}
+	waitFor(t, "old client to close", first.isClosed)
+}
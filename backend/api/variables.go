@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"temporal-aone/backend/pkg"
+	"temporal-aone/backend/shared"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 敏感变量在列表中显示的值
+const maskedValue = "******"
+
+// EnvironmentVariableRequest 设置配置模板变量的请求
+type EnvironmentVariableRequest struct {
+	Value  string `json:"value"`
+	Secret bool   `json:"secret"`
+}
+
+// Handler for listing the template variables of an application environment
+func listEnvironmentVariables(c *gin.Context) {
+	var vars []pkg.EnvironmentVariable
+	err := shared.GetDB().
+		Where("app = ? AND environment = ?", c.Param("app"), c.Param("env")).
+		Order("`key`").
+		Find(&vars).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range vars {
+		if vars[i].Secret {
+			vars[i].Value = maskedValue
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"variables": vars})
+}
+
+// Handler for creating or updating one template variable
+func putEnvironmentVariable(c *gin.Context) {
+	var req EnvironmentVariableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	v := pkg.EnvironmentVariable{
+		App:         c.Param("app"),
+		Environment: c.Param("env"),
+		Key:         c.Param("key"),
+		Value:       req.Value,
+		Secret:      req.Secret,
+	}
+	err := shared.GetDB().Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"value", "secret", "updated_at"}),
+	}).Create(&v).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if v.Secret {
+		v.Value = maskedValue
+	}
+	c.JSON(http.StatusOK, v)
+}
+
+// Handler for deleting one template variable
+func deleteEnvironmentVariable(c *gin.Context) {
+	result := shared.GetDB().
+		Where("app = ? AND environment = ? AND `key` = ?", c.Param("app"), c.Param("env"), c.Param("key")).
+		Delete(&pkg.EnvironmentVariable{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "variable not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Handler for listing the rendered config history of an application
+// environment, newest first; content is omitted, fetch one record for it
+func listRenderedConfigs(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+		return
+	}
+	var configs []pkg.RenderedConfig
+	err = shared.GetDB().
+		Omit("content").
+		Where("app = ? AND environment = ?", c.Param("app"), c.Param("env")).
+		Order("id desc").
+		Limit(limit).
+		Find(&configs).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range configs {
+		if err := pkg.RedactRenderedConfig(shared.GetDB(), &configs[i]); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"rendered_configs": configs})
+}
+
+// Handler for fetching one rendered config with its content and diff;
+// secret variable values are redacted
+func getRenderedConfig(c *gin.Context) {
+	var rendered pkg.RenderedConfig
+	err := shared.GetDB().First(&rendered, c.Param("id")).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "rendered config not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := pkg.RedactRenderedConfig(shared.GetDB(), &rendered); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rendered)
+}
@@ -1,26 +1,132 @@
 package main
 
 import (
+	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 	"temporal-aone/backend/pkg"
+	"temporal-aone/backend/shared"
+	"time"
 
-	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/worker"
 )
 
+// 命令行参数，指定时覆盖配置文件和环境变量
+var (
+	hostPort   = flag.String("host-port", "", "Temporal frontend address, overrides temporal.host_port")
+	namespace  = flag.String("namespace", "", "Temporal namespace, overrides temporal.namespace")
+	taskQueues = flag.String("task-queues", "", "comma separated task queues to poll, overrides worker.task_queues")
+	workspace  = flag.String("workspace", "", "directory for clones and build output, overrides worker.workspace_dir")
+)
+
+// applyFlags 用命令行参数覆盖配置
+func applyFlags() {
+	if *hostPort != "" {
+		shared.Config.Temporal.HostPort = *hostPort
+	}
+	if *namespace != "" {
+		shared.Config.Temporal.Namespace = *namespace
+	}
+	if *taskQueues != "" {
+		shared.Config.Worker.TaskQueues = strings.Split(*taskQueues, ",")
+	}
+	if *workspace != "" {
+		shared.Config.Worker.WorkspaceDir = *workspace
+	}
+}
+
 func main() {
+	flag.Parse()
+
+	// 渲染配置等活动需要读写数据库
+	shared.InitConfig()
+	applyFlags()
+	shared.InitLogger()
+	shared.InitDatabase()
+	if shared.Config.Worker.WorkspaceDir != "" {
+		pkg.Workspace = shared.Config.Worker.WorkspaceDir
+	}
+	if shared.Config.Worker.ArtifactDir != "" {
+		pkg.ArtifactDir = shared.Config.Worker.ArtifactDir
+	}
+	if shared.Config.Worker.ProcessKillGrace > 0 {
+		pkg.ProcessKillGrace = shared.Config.Worker.ProcessKillGrace
+	}
+	if shared.Config.Log.ActivityDir != "" {
+		pkg.ActivityLogs.Dir = shared.Config.Log.ActivityDir
+	}
+	if shared.Config.Log.ActivityMaxBytes > 0 {
+		pkg.ActivityLogs.MaxBytes = shared.Config.Log.ActivityMaxBytes
+	}
+
 	// 创建 Temporal 客户端
-	c, err := client.Dial(client.Options{
-		HostPort: client.DefaultHostPort,
-	})
+	c, err := shared.NewTemporalClient()
 	if err != nil {
 		log.Fatalln("Unable to create Temporal client", err)
 	}
 	defer c.Close()
 
-	// 创建 Worker
-	w := worker.New(c, "release-task-queue", worker.Options{})
+	queues := shared.Config.Worker.TaskQueues
+	if len(queues) == 0 {
+		queues = []string{shared.Config.Temporal.TaskQueue}
+	}
+	workerOptions := worker.Options{
+		MaxConcurrentActivityExecutionSize:     shared.Config.Worker.MaxConcurrentActivities,
+		MaxConcurrentWorkflowTaskExecutionSize: shared.Config.Worker.MaxConcurrentWorkflowTasks,
+		// Stop 时等待运行中活动完成的时间，超时后取消活动的 context
+		WorkerStopTimeout: shared.Config.Worker.ShutdownGracePeriod,
+	}
+
+	// 每个任务队列一个 Worker，注册相同的工作流和活动
+	var workers []worker.Worker
+	for _, queue := range queues {
+		w := worker.New(c, queue, workerOptions)
+		register(w)
+		if err := w.Start(); err != nil {
+			stopWorkers(workers)
+			log.Fatalln("Unable to start Worker", queue, err)
+		}
+		workers = append(workers, w)
+		log.Printf("Worker started on %s (namespace %s, task queue %s)", shared.Config.Temporal.HostPort, shared.Config.Temporal.Namespace, queue)
+	}
+
+	// 等待退出信号，第二次信号时立即退出
+	sig := make(chan os.Signal, 2)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	received := <-sig
+	log.Printf("Received %s, stopping workers (grace period %s)", received, workerOptions.WorkerStopTimeout)
+	go func() {
+		received := <-sig
+		killed := pkg.KillCommands()
+		log.Fatalf("Received %s again, killed %d running commands, exiting without waiting for activities", received, killed)
+	}()
+	stopWorkers(workers)
+	// Stop 超时后取消活动就返回，等待被取消的命令退出，超时后连同子进程强制结束
+	if !pkg.WaitForCommands(pkg.ProcessKillGrace + time.Second) {
+		log.Printf("Killed %d commands still running after shutdown", pkg.KillCommands())
+	}
+	log.Println("Workers stopped")
+}
 
+// stopWorkers 并行停止全部 Worker：不再拉取新任务，等待运行中的活动在宽限期内完成
+func stopWorkers(workers []worker.Worker) {
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w worker.Worker) {
+			defer wg.Done()
+			w.Stop()
+		}(w)
+	}
+	wg.Wait()
+}
+
+// register 注册全部工作流和活动
+func register(w worker.Worker) {
 	// 保存配置流
 	w.RegisterWorkflow(pkg.ConfigWorkflow)
 	w.RegisterActivity(pkg.ConfigRepoActivity)
@@ -32,7 +138,25 @@ func main() {
 
 	//上传流
 	w.RegisterWorkflow(pkg.BuildUploadWorkflow)
+	w.RegisterActivity(pkg.BuildActivity)
+	w.RegisterActivity(pkg.TestActivity)
 	w.RegisterActivity(pkg.UploadOSSActivity)
+	w.RegisterActivity(pkg.StageReleaseActivity)
+	w.RegisterActivity(pkg.RenderConfigActivity)
+	w.RegisterActivity(pkg.CheckoutActivity)
+	w.RegisterActivity(pkg.ChangelogActivity)
+	w.RegisterActivity(pkg.VersionActivity)
+	w.RegisterActivity(pkg.TagVersionActivity)
+	w.RegisterActivity(pkg.StageArtifactActivity)
+
+	//配置发布流
+	w.RegisterWorkflow(pkg.ConfigReleaseWorkflow)
+	w.RegisterActivity(pkg.FetchConfigActivity)
+	w.RegisterActivity(pkg.ValidateConfigActivity)
+	w.RegisterActivity(pkg.DiffConfigActivity)
+	w.RegisterActivity(pkg.DistributeConfigActivity)
+	w.RegisterActivity(pkg.RestoreConfigActivity)
+	w.RegisterActivity(pkg.ReloadConfigActivity)
 
 	//ecs处理流
 	w.RegisterWorkflow(pkg.ReleaseWorkflow)
@@ -41,15 +165,28 @@ func main() {
 	w.RegisterActivity(pkg.GracefulShutdownActivity)
 	w.RegisterActivity(pkg.RestartApplicationActivity)
 	w.RegisterActivity(pkg.HealthCheckActivity)
+	w.RegisterActivity(pkg.ScrapeMetricsActivity)
+	w.RegisterActivity(pkg.RemoteCommandActivity)
+	w.RegisterActivity(pkg.ActiveSlotActivity)
+	w.RegisterActivity(pkg.SwitchTrafficActivity)
+	w.RegisterActivity(pkg.SocketHandoffActivity)
+	w.RegisterActivity(pkg.SystemdDeployActivity)
+	w.RegisterActivity(pkg.SystemdStatusActivity)
+	w.RegisterActivity(pkg.ActivateReleaseActivity)
+	w.RegisterActivity(pkg.ListReleasesActivity)
+	w.RegisterWorkflow(pkg.ListReleasesWorkflow)
+	w.RegisterActivity(pkg.PruneReleasesActivity)
+	w.RegisterActivity(pkg.RecordReleaseActivity)
+	w.RegisterActivity(pkg.RecordHostDeploymentsActivity)
+	w.RegisterActivity(pkg.LoadPolicyActivity)
+	w.RegisterActivity(pkg.RecordOverrideActivity)
+	w.RegisterActivity(pkg.NotifyActivity)
 
-	// 启动 Worker
-	err = w.Start()
-	if err != nil {
-		log.Fatalln("Unable to start Worker", err)
-	}
-
-	log.Println("Worker started successfully")
+	//事件订阅投递流
+	w.RegisterWorkflow(pkg.DispatchEventWorkflow)
+	w.RegisterActivity(pkg.MatchSubscriptionsActivity)
+	w.RegisterActivity(pkg.DeliverEventActivity)
 
-	// 保持 Worker 运行
-	select {}
+	//环境晋级流
+	w.RegisterWorkflow(pkg.PromoteWorkflow)
 }
@@ -8,15 +8,14 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 
-	gosundheit "github.com/AppsFlyer/go-sundheit"
-	"github.com/AppsFlyer/go-sundheit/checks"
-	"github.com/cloudflare/tableflip"
 	"github.com/go-git/go-git/v5"
 	gitHttp "github.com/go-git/go-git/v5/plumbing/transport/http"
 	_ "github.com/mattn/go-sqlite3"
+	"go.temporal.io/sdk/temporal"
 )
 
 type Config struct {
@@ -24,27 +23,98 @@ type Config struct {
 	UserName string
 	Token    string
 	Tag      string
+	// 需要检出的提交，不为空时构建前先检出到 LocalPath
+	Commit string
 
-	BinaryPath     string
+	BinaryPath string
+	// 晋级时为已构建二进制的 sha256，打包前校验，不一致时失败
+	BinaryChecksum string
 	ConfigFilePath string
 	Version        string
 	LocalPath      string
+	// 晋级时为已构建配置模板的 sha256，与二进制一起从产物仓库取回后按目标环境渲染
+	TemplateChecksum string
+	// 版本号生成方式和是否推送版本标签
+	Versioning VersioningSpec
 
 	ECSUploadPath string
 	ECSUser       string
 	ECSServer     string
 
+	// 应用名，为空时使用仓库名；Environment 不为空时按环境变量渲染配置模板
+	App         string
+	Environment string
+	// 发起工作流的操作人，记录在发布记录中
+	Actor string
+
+	// 不在发布窗口内时等待下一个窗口，否则直接失败；Override 不为空时绕过策略并记录审计
+	WaitForWindow bool
+	Override      *OverrideSpec
+
 	AccessKeyID     string
 	AccessKeySecret string
 	RegionID        string
 	ECSIPAddress    string
 
 	HealthCheckURL string
+	HealthCheck    HealthCheckSpec
+
+	// 发布主机列表，为空时只发布到 ECSServer
+	Hosts  []string
+	Canary CanarySpec
+
+	// 发布模式，默认 restart（先停后启），bluegreen 为蓝绿部署，socket-handoff 为 tableflip 平滑重启，
+	// systemd 以 systemd 单元管理应用
+	DeployMode string
+	BlueGreen  BlueGreenSpec
+	Handoff    HandoffSpec
+	Systemd    SystemdSpec
+
+	// 主机上的版本目录布局，启用后上传到 releases/<version>/ 并通过 current 链接切换
+	Releases ReleaseLayout
+
+	// 仅发布配置文件时使用
+	ConfigRelease ConfigReleaseSpec
+
+	// 工作流结束时的通知，Changelog 为构建时生成的变更日志，随通知发送
+	Notify    NotifySpec
+	Changelog string
+
+	// 按步骤覆盖活动的超时和重试配置，键为 Step* 常量
+	Steps map[string]StepOptions
+}
+
+// releaseHosts 返回需要发布的主机
+func (c Config) releaseHosts() []string {
+	if len(c.Hosts) > 0 {
+		return c.Hosts
+	}
+	return []string{c.ECSServer}
 }
 
+// forHost 返回针对单台主机的配置，地址中的 {host} 会被替换
+func (c Config) forHost(host string) Config {
+	c.ECSServer = host
+	c.HealthCheckURL = strings.ReplaceAll(c.HealthCheckURL, "{host}", host)
+	c.HealthCheck.URL = strings.ReplaceAll(c.HealthCheck.URL, "{host}", host)
+	c.HealthCheck.Address = strings.ReplaceAll(c.HealthCheck.Address, "{host}", host)
+	return c
+}
+
+// binaryName 返回主机上的二进制文件名
+func (c Config) binaryName() string {
+	if c.BinaryPath != "" {
+		return filepath.Base(c.BinaryPath)
+	}
+	return "app"
+}
+
+// Workspace 克隆仓库和构建产物的工作目录，Worker 启动时按配置设置
+var Workspace = "reposity"
+
 func generateFolderName(repoURL, tag string) string {
 	repoName := fmt.Sprintf("%s_%s_%s", getRepoName(repoURL), time.Now().Format("20060102150405"), tag)
-	return fmt.Sprintf("reposity/%s", repoName)
+	return fmt.Sprintf("%s/%s", Workspace, repoName)
 }
 
 func getRepoName(repoURL string) string {
@@ -53,22 +123,26 @@ func getRepoName(repoURL string) string {
 }
 
 // validateGitRepo 验证指定的Git仓库地址是否可以连接
-func validateGitRepo(repoURL, username, token, cloneDir string) error {
+func validateGitRepo(repoURL, username, token, cloneDir string, progress io.Writer) error {
 	_, err := git.PlainClone(cloneDir, false, &git.CloneOptions{
 		URL: repoURL,
 		Auth: &gitHttp.BasicAuth{
 			Username: username, // 通常是你的用户名或令牌
 			Password: token,    // 密码或是个人访问令牌
 		},
-		Progress: os.Stdout,
+		Progress: progress,
 	})
 	return err
 }
 
 func ConfigRepoActivity(ctx context.Context, config Config) error {
 	localPath := generateFolderName(config.RepoURL, config.Tag)
+	logs := openActivityLog(ctx)
+	defer logs.Close()
 
-	if err := validateGitRepo(config.RepoURL, config.Tag, config.Token, localPath); err != nil {
+	logs.Printf("Cloning %s into %s", config.RepoURL, localPath)
+	reportProgress(ctx, "cloning %s into %s", config.RepoURL, localPath)
+	if err := validateGitRepo(config.RepoURL, config.Tag, config.Token, localPath, logs); err != nil {
 		return err
 	}
 
@@ -103,68 +177,95 @@ func CheckECSActivity(ctx context.Context, config Config) error {
 }
 
 func BuildActivity(ctx context.Context, config Config) error {
-	fmt.Println("Building the project...")
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+	logs.Printf("Building the project...")
+	reportProgress(ctx, "building %s", config.BinaryPath)
 
-	var stdOut, stdErr bytes.Buffer
+	var stdErr bytes.Buffer
 	cmd := exec.Command("go", "build", "-o", config.BinaryPath, config.LocalPath)
-	cmd.Stdout = &stdOut
-	cmd.Stderr = &stdErr
+	cmd.Stdout = logs
+	cmd.Stderr = io.MultiWriter(&stdErr, logs)
 
-	err := cmd.Run()
+	err := runCommand(ctx, cmd)
 	if err != nil {
-		return fmt.Errorf("build error: %v - stderr: %s", err, stdErr.String())
+		return temporal.NewApplicationError(fmt.Sprintf("build error: %v - stderr: %s", err, stdErr.String()), ErrTypeBuild)
 	}
 
-	fmt.Println("Build output:", stdOut.String())
+	logs.Printf("Build completed")
 	return nil
 }
 
 func TestActivity(ctx context.Context, config Config) error {
-	fmt.Println("Running tests...")
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+	logs.Printf("Running tests...")
+	reportProgress(ctx, "running tests")
 
-	var stdOut, stdErr bytes.Buffer
+	var stdErr bytes.Buffer
 	cmd := exec.Command("go", "test", "./...", config.LocalPath)
-	cmd.Stdout = &stdOut
-	cmd.Stderr = &stdErr
+	cmd.Stdout = logs
+	cmd.Stderr = io.MultiWriter(&stdErr, logs)
 
-	err := cmd.Run()
+	err := runCommand(ctx, cmd)
 	if err != nil {
-		return fmt.Errorf("test error: %v - stderr: %s", err, stdErr.String())
+		return temporal.NewApplicationError(fmt.Sprintf("test error: %v - stderr: %s", err, stdErr.String()), ErrTypeTest)
 	}
 
-	fmt.Println("Test output:", stdOut.String())
+	logs.Printf("Tests passed")
 	return nil
 }
 
-func PackageActivity(ctx context.Context, config Config) error {
-	fmt.Println("Packaging the project...")
+// PackageActivity 打包二进制和配置文件，并记录产物校验和
+func PackageActivity(ctx context.Context, config Config) (Artifact, error) {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+	logs.Printf("Packaging the project...")
+	reportProgress(ctx, "packaging binary and config")
 
-	var stdOut, stdErr bytes.Buffer
+	if err := verifyBinary(config); err != nil {
+		return Artifact{}, err
+	}
+
+	var stdErr bytes.Buffer
 	tarBinaryPath := fmt.Sprintf("%s_binary.tar.gz", config.LocalPath)
 	tarConfigPath := fmt.Sprintf("%s_config.tar.gz", config.LocalPath)
-	cmdBinary := exec.Command("tar", "-czvf", tarBinaryPath, config.BinaryPath)
+	// 二进制放在包的根目录，解压后即为 <dir>/<binary>
+	cmdBinary := exec.Command("tar", "-czvf", tarBinaryPath, "-C", filepath.Dir(config.BinaryPath), filepath.Base(config.BinaryPath))
 	cmdConfig := exec.Command("tar", "-czvf", tarConfigPath, config.ConfigFilePath)
-	cmdBinary.Stdout = &stdOut
-	cmdBinary.Stderr = &stdErr
-	cmdConfig.Stdout = &stdOut
-	cmdConfig.Stderr = &stdErr
+	if config.Environment != "" {
+		// 打包按环境渲染后的配置
+		cmdConfig = exec.Command("tar", "-czvf", tarConfigPath, "-C", config.renderDir(), strings.TrimPrefix(config.ConfigFilePath, "/"))
+	}
+	cmdBinary.Stdout = logs
+	cmdBinary.Stderr = io.MultiWriter(&stdErr, logs)
+	cmdConfig.Stdout = logs
+	cmdConfig.Stderr = io.MultiWriter(&stdErr, logs)
 
-	err := cmdBinary.Run()
+	err := runCommand(ctx, cmdBinary)
 	if err != nil {
-		return fmt.Errorf("package error: %v - stderr: %s", err, stdErr.String())
+		return Artifact{}, fmt.Errorf("package error: %v - stderr: %s", err, stdErr.String())
 	}
 
-	err = cmdConfig.Run()
+	err = runCommand(ctx, cmdConfig)
 	if err != nil {
-		return fmt.Errorf("package error: %v - stderr: %s", err, stdErr.String())
+		return Artifact{}, fmt.Errorf("package error: %v - stderr: %s", err, stdErr.String())
 	}
 
-	fmt.Println("Package output:", stdOut.String())
-	return nil
+	logs.Printf("Packaged %s and %s", tarBinaryPath, tarConfigPath)
+	artifact, err := recordArtifact(ctx, config)
+	if err != nil {
+		return artifact, err
+	}
+	logs.Printf("Binary sha256 %s, config sha256 %s", artifact.BinaryChecksum, artifact.ConfigChecksum)
+	return artifact, nil
 }
 
 func UploadToECSActivity(ctx context.Context, config Config) error {
-	fmt.Println("Uploading packages to ECS...")
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+	logs.Printf("Uploading packages to ECS...")
+	reportProgress(ctx, "uploading packages to %s", config.ECSServer)
 
 	conn, err := net.Dial("tcp", config.ECSServer)
 	if err != nil {
@@ -193,98 +294,91 @@ func UploadToECSActivity(ctx context.Context, config Config) error {
 	if err := uploadFile(tarBinaryPath); err != nil {
 		return err
 	}
+	logs.Printf("Uploaded %s", tarBinaryPath)
+	reportProgress(ctx, "uploaded %s", tarBinaryPath)
 	if err := uploadFile(tarConfigPath); err != nil {
 		return err
 	}
 
-	fmt.Println("Upload completed")
+	logs.Printf("Uploaded %s", tarConfigPath)
+	logs.Printf("Upload completed")
 	return nil
 }
 
-func HealthCheckActivity(ctx context.Context, config Config) error {
-	fmt.Println("Running health checks...")
-
-	// 创建健康检查
-	health := gosundheit.New()
+func HealthCheckActivity(ctx context.Context, config Config) (HealthCheckResult, error) {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+	logs.Printf("Running health checks...")
 
-	// 定义 HTTP 检查配置
-	httpCheckConf := checks.HTTPCheckConfig{
-		CheckName: config.ECSServer + ".health.check",
-		Timeout:   1 * time.Second,
-		URL:       config.HealthCheckURL,
-	}
+	spec := config.HealthCheck.withDefaults(config)
+	reportProgress(ctx, "checking %s", spec.target())
 
-	// 创建 HTTP 健康检查
-	httpCheck, err := checks.NewHTTPCheck(httpCheckConf)
+	result, err := verifyHealth(ctx, spec, logs)
 	if err != nil {
-		fmt.Println(err)
-		return err
-	}
-
-	// 注册健康检查
-	err = health.RegisterCheck(
-		httpCheck,
-		gosundheit.InitialDelay(time.Second),       // 初始延迟
-		gosundheit.ExecutionPeriod(10*time.Second), // 健康检查执行间隔
-	)
-	if err != nil {
-		return fmt.Errorf("registering health check error: %v", err)
-	}
-
-	// 模拟等待一段时间以执行健康检查
-	time.Sleep(30 * time.Second)
-
-	// 获取并检查健康检查结果
-	results, _ := health.Results()
-	if result, ok := results[config.ECSServer+".health.check"]; ok {
-		if result.Error != nil {
-			return fmt.Errorf("health check failed: %v", result.Error)
-		}
-	} else {
-		return fmt.Errorf("health check result not found for %s", config.ECSServer)
+		return result, temporal.NewApplicationError(fmt.Sprintf("health check failed: %v", err), ErrTypeHealthCheck, result)
 	}
 
-	fmt.Println("Health check passed")
-	return nil
+	logs.Printf("Health check passed after %d probes", result.Attempts)
+	return result, nil
 }
 
 func GracefulShutdownActivity(ctx context.Context, config Config) error {
-	fmt.Println("Shutting down the application gracefully...")
-
-	cmd := exec.Command("ssh", fmt.Sprintf("%s@%s", config.ECSUser, config.ECSServer), "pkill -SIGTERM myapp")
-	var stdOut, stdErr bytes.Buffer
-	cmd.Stdout = &stdOut
-	cmd.Stderr = &stdErr
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+	logs.Printf("Shutting down the application gracefully...")
+	reportProgress(ctx, "stopping application on %s", config.ECSServer)
+
+	command := "pkill -SIGTERM myapp"
+	if config.Releases.Enabled {
+		command = config.Releases.withDefaults(config).stopCommand()
+	}
+	cmd := exec.Command("ssh", fmt.Sprintf("%s@%s", config.ECSUser, config.ECSServer), command)
+	var stdErr bytes.Buffer
+	cmd.Stdout = logs
+	cmd.Stderr = io.MultiWriter(&stdErr, logs)
 
-	err := cmd.Run()
+	err := runCommand(ctx, cmd)
 	if err != nil {
-		return fmt.Errorf("graceful shutdown error: %v - stderr: %s", err, stdErr.String())
+		return temporal.NewApplicationError(fmt.Sprintf("graceful shutdown error: %v - stderr: %s", err, stdErr.String()), ErrTypeRemoteCommand)
 	}
 
-	fmt.Println("Graceful shutdown successful:", stdOut.String())
+	logs.Printf("Graceful shutdown successful")
 	return nil
 }
 
 func RestartApplicationActivity(ctx context.Context, config Config) error {
-	fmt.Println("Restarting the application...")
-
-	upgrader, err := tableflip.New(tableflip.Options{})
-	if err != nil {
-		return fmt.Errorf("restart application error: %v", err)
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+	logs.Printf("Restarting the application...")
+	reportProgress(ctx, "starting application on %s", config.ECSServer)
+
+	command := "nohup /path/to/deployed/binary &"
+	if config.Releases.Enabled {
+		// 从 current 链接启动，由链接决定运行的版本
+		command = config.Releases.withDefaults(config).startCommand(config.binaryName())
 	}
-	defer upgrader.Stop()
-
-	cmd := exec.Command("ssh", fmt.Sprintf("%s@%s", config.ECSUser, config.ECSServer), "nohup /path/to/deployed/binary &")
+	cmd := exec.Command("ssh", fmt.Sprintf("%s@%s", config.ECSUser, config.ECSServer), command)
 	cmd.Env = append(os.Environ(), fmt.Sprintf("GIT_ASKPASS=echo '%s'", config.Token))
-	var stdOut, stdErr bytes.Buffer
-	cmd.Stdout = &stdOut
-	cmd.Stderr = &stdErr
+	var stdErr bytes.Buffer
+	cmd.Stdout = logs
+	cmd.Stderr = io.MultiWriter(&stdErr, logs)
 
-	err = cmd.Run()
+	err := runCommand(ctx, cmd)
 	if err != nil {
-		return fmt.Errorf("restart application error: %v - stderr: %s", err, stdErr.String())
+		return temporal.NewApplicationError(fmt.Sprintf("restart application error: %v - stderr: %s", err, stdErr.String()), ErrTypeRemoteCommand)
 	}
 
-	fmt.Println("Application restart successful:", stdOut.String())
+	logs.Printf("Application restart successful")
 	return nil
 }
+
+// RemoteCommandActivity 通过 SSH 在目标主机上执行命令
+func RemoteCommandActivity(ctx context.Context, config Config, command string) error {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+	logs.Printf("Running on %s: %s", config.ECSServer, command)
+	reportProgress(ctx, "running command on %s", config.ECSServer)
+
+	_, err := runRemote(ctx, config, logs, command)
+	return err
+}
@@ -0,0 +1,244 @@
+package pkg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"gorm.io/gorm/clause"
+
+	"temporal-aone/backend/shared"
+)
+
+// ErrTypeArtifactMismatch 晋级时二进制与已构建的产物不一致，重试没有意义
+const ErrTypeArtifactMismatch = "ArtifactMismatchError"
+
+// ArtifactDir 产物仓库目录，打包时按 sha256 保存二进制和配置模板，晋级时从这里取回；
+// 多台 Worker 时应配置为共享存储，为空时使用 Workspace 下的 artifacts
+var ArtifactDir = ""
+
+func artifactStorePath(sum string) string {
+	dir := ArtifactDir
+	if dir == "" {
+		dir = filepath.Join(Workspace, "artifacts")
+	}
+	return filepath.Join(dir, sum)
+}
+
+// Artifact 打包时记录的产物校验和与构建参数，按应用、环境和版本保存
+type Artifact struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	App         string `gorm:"size:128;uniqueIndex:idx_artifact_app_env_version" json:"app"`
+	Environment string `gorm:"size:64;uniqueIndex:idx_artifact_app_env_version" json:"environment"`
+	Version     string `gorm:"size:128;uniqueIndex:idx_artifact_app_env_version" json:"version"`
+	WorkflowID  string `gorm:"size:255" json:"workflow_id"`
+	RunID       string `gorm:"size:64" json:"run_id"`
+
+	BinaryPath     string `gorm:"size:512" json:"binary_path"`
+	BinaryChecksum string `gorm:"size:64" json:"binary_checksum"`
+	BinarySize     int64  `json:"binary_size"`
+	ConfigPath     string `gorm:"size:512" json:"config_path"`
+	ConfigChecksum string `gorm:"size:64" json:"config_checksum"`
+	ConfigSize     int64  `json:"config_size"`
+	// 渲染前的配置模板，晋级时按目标环境重新渲染
+	TemplateChecksum string `gorm:"size:64" json:"template_checksum"`
+
+	// 影响产物的构建参数
+	Params    map[string]string `gorm:"type:text;serializer:json" json:"params"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// fileChecksum 返回文件内容的 sha256 和大小
+func fileChecksum(name string) (string, int64, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// verifyBinary 晋级时确认二进制仍是上一个环境发布的产物，避免发布被后续构建覆盖的文件
+func verifyBinary(config Config) error {
+	if config.BinaryChecksum == "" {
+		return nil
+	}
+	sum, _, err := fileChecksum(config.BinaryPath)
+	if err != nil {
+		return fmt.Errorf("checksum binary error: %v", err)
+	}
+	if sum != config.BinaryChecksum {
+		return temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("binary %s has sha256 %s, built artifact has %s", config.BinaryPath, sum, config.BinaryChecksum),
+			ErrTypeArtifactMismatch, nil)
+	}
+	return nil
+}
+
+// copyFile 通过临时文件写入 dst，写入完成后再 rename，避免留下不完整的文件
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// storeArtifact 把文件按 sha256 保存到产物仓库，已存在时跳过
+func storeArtifact(name, sum string) error {
+	dst := artifactStorePath(sum)
+	if _, err := os.Stat(dst); err == nil {
+		return nil
+	}
+	if err := copyFile(name, dst); err != nil {
+		return fmt.Errorf("store %s in artifact store error: %v", name, err)
+	}
+	return nil
+}
+
+// restoreArtifact 从产物仓库取回 sha256 为 sum 的文件写到 name，取回后再次校验
+func restoreArtifact(sum, name string) error {
+	src := artifactStorePath(sum)
+	if _, err := os.Stat(src); err != nil {
+		return temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("artifact %s not found in artifact store: %v", sum, err), ErrTypeArtifactMismatch, nil)
+	}
+	if err := copyFile(src, name); err != nil {
+		return fmt.Errorf("restore artifact %s to %s error: %v", sum, name, err)
+	}
+	got, _, err := fileChecksum(name)
+	if err != nil {
+		return fmt.Errorf("checksum %s error: %v", name, err)
+	}
+	if got != sum {
+		return temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("artifact store entry %s has sha256 %s", sum, got), ErrTypeArtifactMismatch, nil)
+	}
+	return nil
+}
+
+// StageArtifactActivity 晋级时从产物仓库取回上一个环境发布的二进制和配置模板，
+// 不依赖执行构建的 Worker 和它的工作目录
+func StageArtifactActivity(ctx context.Context, config Config) error {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+
+	if config.BinaryChecksum == "" {
+		return temporal.NewNonRetryableApplicationError("promotion needs the binary checksum of the built artifact", ErrTypeArtifactMismatch, nil)
+	}
+	logs.Printf("Restoring binary %s to %s", config.BinaryChecksum, config.BinaryPath)
+	reportProgress(ctx, "restoring artifact %s", config.BinaryChecksum)
+	if err := restoreArtifact(config.BinaryChecksum, config.BinaryPath); err != nil {
+		return err
+	}
+	if config.TemplateChecksum != "" {
+		logs.Printf("Restoring config template %s to %s", config.TemplateChecksum, config.ConfigFilePath)
+		if err := restoreArtifact(config.TemplateChecksum, config.ConfigFilePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildParams 返回影响产物的构建参数，用于比较两次发布
+func buildParams(config Config) map[string]string {
+	params := map[string]string{
+		"repo_url":         config.RepoURL,
+		"tag":              config.Tag,
+		"commit":           config.Commit,
+		"binary_path":      config.BinaryPath,
+		"config_file_path": config.ConfigFilePath,
+		"deploy_mode":      config.DeployMode,
+		"hosts":            strings.Join(config.releaseHosts(), ","),
+		"versioned":        strconv.FormatBool(config.Releases.Enabled),
+	}
+	if config.Versioning.Strategy != "" {
+		params["versioning"] = config.Versioning.Strategy
+	}
+	return params
+}
+
+// recordArtifact 计算产物校验和并写入数据库，同一应用、环境和版本重复打包时覆盖
+func recordArtifact(ctx context.Context, config Config) (Artifact, error) {
+	artifact := Artifact{
+		App:         config.AppName(),
+		Environment: config.Environment,
+		Version:     config.Version,
+		BinaryPath:  config.BinaryPath,
+		ConfigPath:  config.ConfigFilePath,
+		Params:      buildParams(config),
+	}
+	if config.Environment != "" {
+		artifact.ConfigPath = config.renderDir() + "/" + strings.TrimPrefix(config.ConfigFilePath, "/")
+	}
+	if ctx != nil && activity.IsActivity(ctx) {
+		info := activity.GetInfo(ctx)
+		artifact.WorkflowID = info.WorkflowExecution.ID
+		artifact.RunID = info.WorkflowExecution.RunID
+	}
+
+	var err error
+	if artifact.BinaryChecksum, artifact.BinarySize, err = fileChecksum(artifact.BinaryPath); err != nil {
+		return artifact, fmt.Errorf("checksum binary error: %v", err)
+	}
+	if artifact.ConfigChecksum, artifact.ConfigSize, err = fileChecksum(artifact.ConfigPath); err != nil {
+		return artifact, fmt.Errorf("checksum config error: %v", err)
+	}
+	if artifact.TemplateChecksum, _, err = fileChecksum(config.ConfigFilePath); err != nil {
+		return artifact, fmt.Errorf("checksum config template error: %v", err)
+	}
+	// 晋级时从产物仓库取回，而不是依赖构建时的工作目录
+	if err := storeArtifact(artifact.BinaryPath, artifact.BinaryChecksum); err != nil {
+		return artifact, err
+	}
+	if err := storeArtifact(config.ConfigFilePath, artifact.TemplateChecksum); err != nil {
+		return artifact, err
+	}
+
+	err = shared.GetDB().Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "app"}, {Name: "environment"}, {Name: "version"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"workflow_id", "run_id", "binary_path", "binary_checksum", "binary_size",
+			"config_path", "config_checksum", "config_size", "template_checksum", "params", "updated_at",
+		}),
+	}).Create(&artifact).Error
+	if err != nil {
+		return artifact, fmt.Errorf("record artifact error: %v", err)
+	}
+	return artifact, nil
+}
@@ -0,0 +1,198 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// 发布模式
+const (
+	DeployModeRestart   = "restart"
+	DeployModeBlueGreen = "bluegreen"
+)
+
+// BlueGreenSlot 蓝绿部署中的一个槽位
+type BlueGreenSlot struct {
+	Name string
+	Dir  string
+	Port int
+}
+
+// BlueGreenSpec 蓝绿部署配置，命令中可使用 {slot} {dir} {port} {host} {upload} {binary} {package} {release} 占位符，
+// {package} 为上传包的文件名前缀，{release} 为启用版本目录时暂存的版本目录
+type BlueGreenSpec struct {
+	Blue  BlueGreenSlot
+	Green BlueGreenSlot
+	// 主机上记录当前生效槽位的文件
+	StateFile string
+
+	// 启动前把新版本放进槽位目录，默认解压上传的包，启用版本目录时复制暂存的版本目录
+	InstallCommand string
+	StartCommand   string
+	StopCommand    string
+
+	// 切换流量：优先执行 SwitchCommand，否则用模板重写反向代理配置并执行重载命令
+	SwitchCommand       string
+	ProxyConfigFile     string
+	ProxyConfigTemplate string
+	ProxyReloadCommand  string
+
+	// 切换后旧实例继续处理存量请求的时间
+	DrainPeriod time.Duration
+}
+
+func (s BlueGreenSpec) withDefaults(config Config) BlueGreenSpec {
+	base := config.ECSUploadPath
+	if base == "" {
+		base = "."
+	}
+	if s.Blue.Name == "" {
+		s.Blue.Name = "blue"
+	}
+	if s.Blue.Dir == "" {
+		s.Blue.Dir = path.Join(base, s.Blue.Name)
+	}
+	if s.Blue.Port == 0 {
+		s.Blue.Port = 8080
+	}
+	if s.Green.Name == "" {
+		s.Green.Name = "green"
+	}
+	if s.Green.Dir == "" {
+		s.Green.Dir = path.Join(base, s.Green.Name)
+	}
+	if s.Green.Port == 0 {
+		s.Green.Port = 8081
+	}
+	if s.StateFile == "" {
+		s.StateFile = path.Join(base, "active_slot")
+	}
+	if s.InstallCommand == "" {
+		s.InstallCommand = "mkdir -p {dir} && tar -xzf {upload}/{package}_binary.tar.gz -C {dir} && tar -xzf {upload}/{package}_config.tar.gz -C {dir}"
+		if config.Releases.Enabled {
+			s.InstallCommand = "mkdir -p {dir} && cp -a {release}/. {dir}/"
+		}
+	}
+	if s.StartCommand == "" {
+		s.StartCommand = "cd {dir} && (PORT={port} nohup ./{binary} > app.log 2>&1 & echo $! > app.pid)"
+	}
+	if s.StopCommand == "" {
+		s.StopCommand = "cd {dir} && test -f app.pid && kill -TERM $(cat app.pid) && rm -f app.pid || true"
+	}
+	if s.DrainPeriod <= 0 {
+		s.DrainPeriod = 30 * time.Second
+	}
+	return s
+}
+
+// slots 根据当前生效槽位返回新旧槽位，未知状态时视为蓝色槽位生效
+func (s BlueGreenSpec) slots(active string) (current, target BlueGreenSlot) {
+	if active == s.Green.Name {
+		return s.Green, s.Blue
+	}
+	return s.Blue, s.Green
+}
+
+// render 替换命令模板中的占位符
+func (s BlueGreenSpec) render(template string, config Config, slot BlueGreenSlot) string {
+	return strings.NewReplacer(
+		"{slot}", slot.Name,
+		"{dir}", slot.Dir,
+		"{port}", strconv.Itoa(slot.Port),
+		"{host}", config.ECSServer,
+		"{upload}", config.ECSUploadPath,
+		"{binary}", config.binaryName(),
+		"{package}", filepath.Base(config.LocalPath),
+		"{release}", config.Releases.withDefaults(config).releaseDir(config.Version),
+	).Replace(template)
+}
+
+// switchScript 生成切换流量并记录生效槽位的脚本
+func (s BlueGreenSpec) switchScript(config Config, slot BlueGreenSlot) (string, error) {
+	var steps []string
+	switch {
+	case s.SwitchCommand != "":
+		steps = append(steps, s.render(s.SwitchCommand, config, slot))
+	case s.ProxyConfigFile != "" && s.ProxyConfigTemplate != "":
+		content := s.render(s.ProxyConfigTemplate, config, slot)
+		tmp := s.ProxyConfigFile + ".tmp"
+		// 先写临时文件再 mv，保证代理读到的配置是完整的
+		steps = append(steps, fmt.Sprintf("printf '%%s' %s > %s && mv %s %s",
+			shellQuote(content), shellQuote(tmp), shellQuote(tmp), shellQuote(s.ProxyConfigFile)))
+		if s.ProxyReloadCommand != "" {
+			steps = append(steps, s.render(s.ProxyReloadCommand, config, slot))
+		}
+	default:
+		return "", fmt.Errorf("blue/green release needs a switch command or a proxy config file and template")
+	}
+	steps = append(steps, fmt.Sprintf("echo %s > %s", shellQuote(slot.Name), shellQuote(s.StateFile)))
+	return strings.Join(steps, " && "), nil
+}
+
+// forSlot 返回针对某个槽位的配置，健康检查地址中的 {port} 会被替换
+func (c Config) forSlot(slot BlueGreenSlot) Config {
+	port := strconv.Itoa(slot.Port)
+	c.HealthCheckURL = strings.ReplaceAll(c.HealthCheckURL, "{port}", port)
+	c.HealthCheck.URL = strings.ReplaceAll(c.HealthCheck.URL, "{port}", port)
+	c.HealthCheck.Address = strings.ReplaceAll(c.HealthCheck.Address, "{port}", port)
+	return c
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+// runRemote 通过 SSH 执行命令，输出写入活动日志并返回标准输出
+func runRemote(ctx context.Context, config Config, logs *activityLog, command string) (string, error) {
+	cmd := exec.Command("ssh", fmt.Sprintf("%s@%s", config.ECSUser, config.ECSServer), command)
+	var stdOut, stdErr bytes.Buffer
+	cmd.Stdout = io.MultiWriter(&stdOut, logs)
+	cmd.Stderr = io.MultiWriter(&stdErr, logs)
+
+	err := runCommand(ctx, cmd)
+	if err != nil {
+		return "", temporal.NewApplicationError(fmt.Sprintf("remote command error: %v - stderr: %s", err, stdErr.String()), ErrTypeRemoteCommand)
+	}
+	return stdOut.String(), nil
+}
+
+// ActiveSlotActivity 读取主机上当前生效的槽位
+func ActiveSlotActivity(ctx context.Context, config Config) (string, error) {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+
+	spec := config.BlueGreen.withDefaults(config)
+	output, err := runRemote(ctx, config, logs, fmt.Sprintf("cat %s 2>/dev/null || true", shellQuote(spec.StateFile)))
+	if err != nil {
+		return "", err
+	}
+	active := strings.TrimSpace(output)
+	logs.Printf("Active slot on %s: %q", config.ECSServer, active)
+	return active, nil
+}
+
+// SwitchTrafficActivity 将流量切换到指定槽位
+func SwitchTrafficActivity(ctx context.Context, config Config, slot BlueGreenSlot) error {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+
+	spec := config.BlueGreen.withDefaults(config)
+	script, err := spec.switchScript(config, slot)
+	if err != nil {
+		return temporal.NewNonRetryableApplicationError(err.Error(), ErrTypeRemoteCommand, nil)
+	}
+	logs.Printf("Switching %s traffic to %s (port %d)", config.ECSServer, slot.Name, slot.Port)
+	reportProgress(ctx, "switching traffic to %s", slot.Name)
+	_, err = runRemote(ctx, config, logs, script)
+	return err
+}
@@ -0,0 +1,78 @@
+package pkg
+
+import "testing"
+
+func TestBlueGreenSwitchScript(t *testing.T) {
+	config := Config{ECSServer: "10.0.0.1", ECSUploadPath: "/opt/app", BinaryPath: "build/myapp"}
+	tests := []struct {
+		name    string
+		spec    BlueGreenSpec
+		active  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "switch command",
+			spec:   BlueGreenSpec{SwitchCommand: "lb-switch --host {host} --port {port}"},
+			active: "blue",
+			want:   "lb-switch --host 10.0.0.1 --port 8081 && echo 'green' > '/opt/app/active_slot'",
+		},
+		{
+			name: "proxy config rewrite",
+			spec: BlueGreenSpec{
+				ProxyConfigFile:     "/etc/nginx/conf.d/app.conf",
+				ProxyConfigTemplate: "upstream app { server 127.0.0.1:{port}; } # it's {slot}",
+				ProxyReloadCommand:  "nginx -s reload",
+			},
+			active: "green",
+			want: "printf '%s' 'upstream app { server 127.0.0.1:8080; } # it'\"'\"'s blue' > '/etc/nginx/conf.d/app.conf.tmp'" +
+				" && mv '/etc/nginx/conf.d/app.conf.tmp' '/etc/nginx/conf.d/app.conf'" +
+				" && nginx -s reload && echo 'blue' > '/opt/app/active_slot'",
+		},
+		{
+			name:    "no switch method",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := tt.spec.withDefaults(config)
+			_, target := spec.slots(tt.active)
+			got, err := spec.switchScript(config, target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("switchScript() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("switchScript() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlueGreenInstallCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{
+			name:   "uploaded packages",
+			config: Config{ECSUploadPath: "/opt/app", LocalPath: "reposity/app.git_20240101_v1", BinaryPath: "build/myapp"},
+			want:   "mkdir -p /opt/app/green && tar -xzf /opt/app/app.git_20240101_v1_binary.tar.gz -C /opt/app/green && tar -xzf /opt/app/app.git_20240101_v1_config.tar.gz -C /opt/app/green",
+		},
+		{
+			name:   "staged release",
+			config: Config{ECSUploadPath: "/opt/app", Version: "v1.2.0", Releases: ReleaseLayout{Enabled: true}},
+			want:   "mkdir -p /opt/app/green && cp -a /opt/app/releases/v1.2.0/. /opt/app/green/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := BlueGreenSpec{}.withDefaults(tt.config)
+			_, target := spec.slots("blue")
+			if got := spec.render(spec.InstallCommand, tt.config, target); got != tt.want {
+				t.Errorf("install command = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CanarySpec 金丝雀发布配置，零值字段使用默认值
+type CanarySpec struct {
+	Enabled bool
+	// 金丝雀主机，为空时取发布主机列表的前 Count 台
+	Hosts []string
+	Count int
+
+	// Prometheus 指标地址，{host} 会被替换为主机地址
+	MetricsURL string
+	// 请求计数器，ErrorLabel 的值以 ErrorValuePrefix 开头的样本记为错误
+	RequestsMetric   string
+	ErrorLabel       string
+	ErrorValuePrefix string
+	// 延迟直方图或摘要的名称，使用 _sum/_count 计算平均延迟
+	LatencyMetric string
+
+	Window time.Duration
+	// 金丝雀错误率最多比基线高出的值（0.01 表示 1 个百分点）
+	MaxErrorRateDelta float64
+	// 金丝雀平均延迟与基线的最大比值
+	MaxLatencyRatio float64
+	// 窗口内金丝雀至少需要的请求数，不足时视为分析失败
+	MinRequests float64
+
+	// 分析失败时在金丝雀主机上执行的回滚命令，为空时需要启用版本目录，回滚到金丝雀之前的版本
+	RollbackCommand string
+}
+
+// MetricsSnapshot 某台主机在某一时刻的指标汇总
+type MetricsSnapshot struct {
+	Host         string
+	Requests     float64
+	Errors       float64
+	LatencySum   float64
+	LatencyCount float64
+}
+
+// CanaryAnalysis 金丝雀与基线的对比结果
+type CanaryAnalysis struct {
+	CanaryHosts       []string
+	BaselineHosts     []string
+	CanaryRequests    float64
+	BaselineRequests  float64
+	CanaryErrorRate   float64
+	BaselineErrorRate float64
+	CanaryLatency     float64
+	BaselineLatency   float64
+	Passed            bool
+	Reasons           []string
+}
+
+func (s CanarySpec) withDefaults() CanarySpec {
+	if s.Count <= 0 {
+		s.Count = 1
+	}
+	if s.RequestsMetric == "" {
+		s.RequestsMetric = "http_requests_total"
+	}
+	if s.ErrorLabel == "" {
+		s.ErrorLabel = "code"
+	}
+	if s.ErrorValuePrefix == "" {
+		s.ErrorValuePrefix = "5"
+	}
+	if s.LatencyMetric == "" {
+		s.LatencyMetric = "http_request_duration_seconds"
+	}
+	if s.Window <= 0 {
+		s.Window = 5 * time.Minute
+	}
+	if s.MaxErrorRateDelta <= 0 {
+		s.MaxErrorRateDelta = 0.01
+	}
+	if s.MaxLatencyRatio <= 0 {
+		s.MaxLatencyRatio = 1.5
+	}
+	// 没有流量的金丝雀无法证明新版本正常
+	if s.MinRequests <= 0 {
+		s.MinRequests = 100
+	}
+	return s
+}
+
+// canRollbackCanary 金丝雀分析失败时是否能回滚：配置了回滚命令，或启用版本目录时切回之前的版本
+func (c Config) canRollbackCanary() bool {
+	return c.Canary.RollbackCommand != "" || c.Releases.Enabled
+}
+
+// split 将发布主机分为金丝雀和基线两组，基线至少保留一台
+func (s CanarySpec) split(hosts []string) (canary, baseline []string, err error) {
+	if len(hosts) < 2 {
+		return nil, nil, fmt.Errorf("canary release needs at least 2 hosts, got %d", len(hosts))
+	}
+	if len(s.Hosts) == 0 {
+		count := s.Count
+		if count >= len(hosts) {
+			count = len(hosts) - 1
+		}
+		return hosts[:count], hosts[count:], nil
+	}
+
+	selected := make(map[string]bool, len(s.Hosts))
+	for _, host := range s.Hosts {
+		selected[host] = true
+	}
+	for _, host := range hosts {
+		if selected[host] {
+			canary = append(canary, host)
+		} else {
+			baseline = append(baseline, host)
+		}
+	}
+	if len(canary) != len(s.Hosts) {
+		return nil, nil, fmt.Errorf("canary hosts %v are not all in release hosts %v", s.Hosts, hosts)
+	}
+	if len(baseline) == 0 {
+		return nil, nil, fmt.Errorf("canary hosts cover all release hosts, no baseline left")
+	}
+	return canary, baseline, nil
+}
+
+// ScrapeMetricsActivity 抓取各主机的 Prometheus 指标并汇总
+func ScrapeMetricsActivity(ctx context.Context, spec CanarySpec, hosts []string) (map[string]MetricsSnapshot, error) {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+
+	snapshots := make(map[string]MetricsSnapshot, len(hosts))
+	for _, host := range hosts {
+		url := strings.ReplaceAll(spec.MetricsURL, "{host}", host)
+		reportProgress(ctx, "scraping %s", url)
+		snapshot, err := scrapeMetrics(ctx, url, spec)
+		if err != nil {
+			return nil, fmt.Errorf("scrape metrics from %s error: %v", url, err)
+		}
+		snapshot.Host = host
+		logs.Printf("%s: requests=%.0f errors=%.0f latency_sum=%.3f latency_count=%.0f",
+			host, snapshot.Requests, snapshot.Errors, snapshot.LatencySum, snapshot.LatencyCount)
+		snapshots[host] = snapshot
+	}
+	return snapshots, nil
+}
+
+func scrapeMetrics(ctx context.Context, url string, spec CanarySpec) (MetricsSnapshot, error) {
+	var snapshot MetricsSnapshot
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return snapshot, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return snapshot, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return snapshot, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	samples, err := parseMetrics(resp.Body)
+	if err != nil {
+		return snapshot, err
+	}
+	return summarizeMetrics(samples, spec), nil
+}
+
+// metricSample Prometheus 文本格式中的一条样本
+type metricSample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+// parseMetrics 解析 Prometheus 文本格式，忽略注释和无法解析的值
+func parseMetrics(r io.Reader) ([]metricSample, error) {
+	var samples []metricSample
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var sample metricSample
+		rest := line
+		if i := strings.IndexAny(line, "{ "); i >= 0 && line[i] == '{' {
+			end := strings.LastIndex(line, "}")
+			if end < i {
+				return nil, fmt.Errorf("invalid metric line: %s", line)
+			}
+			sample.Name = line[:i]
+			sample.Labels = parseLabels(line[i+1 : end])
+			rest = line[end+1:]
+		} else {
+			fields := strings.Fields(line)
+			sample.Name = fields[0]
+			rest = strings.TrimPrefix(line, fields[0])
+		}
+
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("missing value: %s", line)
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		sample.Value = value
+		samples = append(samples, sample)
+	}
+	return samples, scanner.Err()
+}
+
+func parseLabels(s string) map[string]string {
+	labels := make(map[string]string)
+	for len(s) > 0 {
+		eq := strings.Index(s, "=")
+		if eq < 0 {
+			break
+		}
+		name := strings.TrimSpace(strings.TrimLeft(s[:eq], ","))
+		s = s[eq+1:]
+		if !strings.HasPrefix(s, `"`) {
+			break
+		}
+		// 查找未转义的结束引号
+		end := 1
+		for end < len(s) && (s[end] != '"' || s[end-1] == '\\') {
+			end++
+		}
+		if end >= len(s) {
+			break
+		}
+		labels[name] = strings.ReplaceAll(s[1:end], `\"`, `"`)
+		s = s[end+1:]
+	}
+	return labels
+}
+
+func summarizeMetrics(samples []metricSample, spec CanarySpec) MetricsSnapshot {
+	var snapshot MetricsSnapshot
+	for _, sample := range samples {
+		switch sample.Name {
+		case spec.RequestsMetric:
+			snapshot.Requests += sample.Value
+			if strings.HasPrefix(sample.Labels[spec.ErrorLabel], spec.ErrorValuePrefix) {
+				snapshot.Errors += sample.Value
+			}
+		case spec.LatencyMetric + "_sum":
+			snapshot.LatencySum += sample.Value
+		case spec.LatencyMetric + "_count":
+			snapshot.LatencyCount += sample.Value
+		}
+	}
+	return snapshot
+}
+
+// counterDelta 计算计数器增量，计数器重置（如进程重启）时直接使用新值
+func counterDelta(before, after float64) float64 {
+	if after < before {
+		return after
+	}
+	return after - before
+}
+
+// aggregateWindow 汇总一组主机在窗口内的指标增量
+func aggregateWindow(before, after map[string]MetricsSnapshot, hosts []string) MetricsSnapshot {
+	var total MetricsSnapshot
+	for _, host := range hosts {
+		b, a := before[host], after[host]
+		total.Requests += counterDelta(b.Requests, a.Requests)
+		total.Errors += counterDelta(b.Errors, a.Errors)
+		total.LatencySum += counterDelta(b.LatencySum, a.LatencySum)
+		total.LatencyCount += counterDelta(b.LatencyCount, a.LatencyCount)
+	}
+	return total
+}
+
+func ratio(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
+
+// analyzeCanary 比较窗口内金丝雀和基线的错误率与平均延迟，工作流中调用，必须保持确定性
+func analyzeCanary(spec CanarySpec, before, after map[string]MetricsSnapshot, canaryHosts, baselineHosts []string) CanaryAnalysis {
+	canary := aggregateWindow(before, after, canaryHosts)
+	baseline := aggregateWindow(before, after, baselineHosts)
+
+	analysis := CanaryAnalysis{
+		CanaryHosts:       canaryHosts,
+		BaselineHosts:     baselineHosts,
+		CanaryRequests:    canary.Requests,
+		BaselineRequests:  baseline.Requests,
+		CanaryErrorRate:   ratio(canary.Errors, canary.Requests),
+		BaselineErrorRate: ratio(baseline.Errors, baseline.Requests),
+		CanaryLatency:     ratio(canary.LatencySum, canary.LatencyCount),
+		BaselineLatency:   ratio(baseline.LatencySum, baseline.LatencyCount),
+	}
+
+	if canary.Requests < spec.MinRequests {
+		analysis.Reasons = append(analysis.Reasons,
+			fmt.Sprintf("canary served %.0f requests, need at least %.0f", canary.Requests, spec.MinRequests))
+	}
+	if delta := analysis.CanaryErrorRate - analysis.BaselineErrorRate; delta > spec.MaxErrorRateDelta {
+		analysis.Reasons = append(analysis.Reasons,
+			fmt.Sprintf("canary error rate %.4f exceeds baseline %.4f by more than %.4f",
+				analysis.CanaryErrorRate, analysis.BaselineErrorRate, spec.MaxErrorRateDelta))
+	}
+	if analysis.BaselineLatency > 0 && analysis.CanaryLatency > analysis.BaselineLatency*spec.MaxLatencyRatio {
+		analysis.Reasons = append(analysis.Reasons,
+			fmt.Sprintf("canary latency %.4fs exceeds %.1fx baseline %.4fs",
+				analysis.CanaryLatency, spec.MaxLatencyRatio, analysis.BaselineLatency))
+	}
+	analysis.Passed = len(analysis.Reasons) == 0
+	return analysis
+}
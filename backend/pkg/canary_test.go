@@ -0,0 +1,143 @@
+package pkg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testMetrics = `# HELP http_requests_total Total requests.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",code="200"} 90
+http_requests_total{method="GET",code="500"} 10
+http_requests_total{method="POST",path="/a\"b",code="503"} 5
+http_request_duration_seconds_sum 21
+http_request_duration_seconds_count 105
+process_start_time_seconds 1.7e+09
+`
+
+func TestSummarizeMetrics(t *testing.T) {
+	samples, err := parseMetrics(strings.NewReader(testMetrics))
+	if err != nil {
+		t.Fatalf("parseMetrics() error = %v", err)
+	}
+	if got := samples[2].Labels["path"]; got != `/a"b` {
+		t.Errorf("parseMetrics() escaped label = %q", got)
+	}
+	got := summarizeMetrics(samples, CanarySpec{}.withDefaults())
+	want := MetricsSnapshot{Requests: 105, Errors: 15, LatencySum: 21, LatencyCount: 105}
+	if got != want {
+		t.Errorf("summarizeMetrics() = %+v, want %+v", got, want)
+	}
+}
+
+func TestAnalyzeCanary(t *testing.T) {
+	spec := CanarySpec{MinRequests: 10}.withDefaults()
+	before := map[string]MetricsSnapshot{
+		"canary":   {Requests: 0},
+		"baseline": {Requests: 1000, Errors: 10, LatencySum: 100, LatencyCount: 1000},
+	}
+	tests := []struct {
+		name    string
+		canary  MetricsSnapshot
+		passed  bool
+		reasons int
+	}{
+		{
+			name:   "healthy canary",
+			canary: MetricsSnapshot{Requests: 100, Errors: 1, LatencySum: 11, LatencyCount: 100},
+			passed: true,
+		},
+		{
+			name:    "error rate regression",
+			canary:  MetricsSnapshot{Requests: 100, Errors: 10, LatencySum: 10, LatencyCount: 100},
+			reasons: 1,
+		},
+		{
+			name:    "latency regression and low traffic",
+			canary:  MetricsSnapshot{Requests: 5, LatencySum: 1, LatencyCount: 5},
+			reasons: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after := map[string]MetricsSnapshot{
+				"canary":   tt.canary,
+				"baseline": {Requests: 2000, Errors: 20, LatencySum: 200, LatencyCount: 2000},
+			}
+			got := analyzeCanary(spec, before, after, []string{"canary"}, []string{"baseline"})
+			if got.Passed != tt.passed || len(got.Reasons) != tt.reasons {
+				t.Errorf("analyzeCanary() = %+v, want passed %v with %d reasons", got, tt.passed, tt.reasons)
+			}
+		})
+	}
+}
+
+func TestCanarySplit(t *testing.T) {
+	hosts := []string{"a", "b", "c"}
+	tests := []struct {
+		name         string
+		spec         CanarySpec
+		canary, base []string
+		wantErr      bool
+	}{
+		{name: "first count hosts", spec: CanarySpec{Count: 1}, canary: []string{"a"}, base: []string{"b", "c"}},
+		{name: "keeps one baseline", spec: CanarySpec{Count: 5}, canary: []string{"a", "b"}, base: []string{"c"}},
+		{name: "explicit hosts", spec: CanarySpec{Hosts: []string{"c"}}, canary: []string{"c"}, base: []string{"a", "b"}},
+		{name: "unknown host", spec: CanarySpec{Hosts: []string{"d"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			canary, base, err := tt.spec.split(hosts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("split() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (!reflect.DeepEqual(canary, tt.canary) || !reflect.DeepEqual(base, tt.base)) {
+				t.Errorf("split() = %v, %v, want %v, %v", canary, base, tt.canary, tt.base)
+			}
+		})
+	}
+}
+
+func TestCanaryRollbackRequired(t *testing.T) {
+	if got := (CanarySpec{}).withDefaults().MinRequests; got <= 0 {
+		t.Errorf("default MinRequests = %v, want > 0", got)
+	}
+
+	tests := []struct {
+		name   string
+		config Config
+		want   bool
+	}{
+		{"nothing to roll back with", Config{}, false},
+		{"rollback command", Config{Canary: CanarySpec{RollbackCommand: "./rollback.sh"}}, true},
+		{"versioned releases", Config{Releases: ReleaseLayout{Enabled: true}}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.config.canRollbackCanary(); got != tt.want {
+			t.Errorf("%s: canRollbackCanary() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSwitchedCanaryHosts(t *testing.T) {
+	canaryHosts := []string{"a", "b", "c"}
+	tests := []struct {
+		name     string
+		config   Config
+		failed   int
+		previous map[string]string
+		want     []string
+	}{
+		{"first host failed before switching", Config{Releases: ReleaseLayout{Enabled: true}}, 0, map[string]string{}, []string{}},
+		{"activation failed", Config{Releases: ReleaseLayout{Enabled: true}}, 1, map[string]string{"a": "v1"}, []string{"a"}},
+		{"restart failed after activation", Config{Releases: ReleaseLayout{Enabled: true}}, 2, map[string]string{"a": "v1", "b": "v1", "c": "v1"}, []string{"a", "b", "c"}},
+		{"rollback command", Config{Canary: CanarySpec{RollbackCommand: "./rollback.sh"}}, 1, map[string]string{}, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		got := switchedCanaryHosts(tt.config, canaryHosts, tt.failed, tt.previous)
+		if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("%s: switchedCanaryHosts() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"temporal-aone/backend/shared"
+)
+
+// 变更日志最多包含的提交数，上一次发布的提交不在历史中时（例如首次发布）按此截断
+const maxChangelogCommits = 200
+
+// conventionalCommit 匹配 type(scope)!: subject
+var conventionalCommit = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?(!)?:\s*(.+)$`)
+
+// 约定式提交类型在变更日志中的分类，按顺序输出
+var changelogCategories = []struct {
+	Type  string
+	Title string
+}{
+	{"breaking", "Breaking Changes"},
+	{"feat", "Features"},
+	{"fix", "Bug Fixes"},
+	{"perf", "Performance"},
+	{"refactor", "Refactoring"},
+	{"revert", "Reverts"},
+	{"docs", "Documentation"},
+	{"test", "Tests"},
+	{"build", "Build"},
+	{"ci", "CI"},
+	{"chore", "Chores"},
+	{"style", "Style"},
+	{"other", "Other Changes"},
+}
+
+// ChangelogEntry 变更日志中的一个提交
+type ChangelogEntry struct {
+	Hash     string    `json:"hash"`
+	Type     string    `json:"type"`
+	Scope    string    `json:"scope,omitempty"`
+	Subject  string    `json:"subject"`
+	Breaking bool      `json:"breaking,omitempty"`
+	Author   string    `json:"author"`
+	Email    string    `json:"email"`
+	Time     time.Time `json:"time"`
+}
+
+// Changelog 两次发布之间的提交，按应用和版本保存
+type Changelog struct {
+	ID      uint   `gorm:"primaryKey" json:"id"`
+	App     string `gorm:"size:128;uniqueIndex:idx_changelog_app_version" json:"app"`
+	Version string `gorm:"size:128;uniqueIndex:idx_changelog_app_version" json:"version"`
+	// 上一次成功发布的提交，为空表示没有找到
+	FromCommit string           `gorm:"size:64" json:"from_commit"`
+	ToCommit   string           `gorm:"size:64" json:"to_commit"`
+	Entries    []ChangelogEntry `gorm:"type:longtext;serializer:json" json:"entries"`
+	Authors    []string         `gorm:"type:text;serializer:json" json:"authors"`
+	Truncated  bool             `json:"truncated"`
+	Markdown   string           `gorm:"type:longtext" json:"markdown"`
+	CreatedAt  time.Time        `json:"created_at"`
+}
+
+// parseConventionalCommit 解析约定式提交的首行，不符合约定时类型为 other
+func parseConventionalCommit(message string) (typ, scope, subject string, breaking bool) {
+	first, body, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	first = strings.TrimSpace(first)
+	breaking = strings.Contains(body, "BREAKING CHANGE:") || strings.Contains(body, "BREAKING-CHANGE:")
+
+	m := conventionalCommit.FindStringSubmatch(first)
+	if m == nil {
+		return "other", "", first, breaking
+	}
+	typ = strings.ToLower(m[1])
+	known := false
+	for _, c := range changelogCategories {
+		if c.Type == typ {
+			known = true
+			break
+		}
+	}
+	if !known || typ == "breaking" {
+		typ = "other"
+	}
+	return typ, m[2], m[4], breaking || m[3] == "!"
+}
+
+// buildChangelog 从 to 沿历史回溯到 from（不含），跳过合并提交
+func buildChangelog(repo *git.Repository, from, to string) (Changelog, error) {
+	changelog := Changelog{FromCommit: from, ToCommit: to}
+	iter, err := repo.Log(&git.LogOptions{From: plumbing.NewHash(to)})
+	if err != nil {
+		return changelog, err
+	}
+	defer iter.Close()
+
+	authors := map[string]bool{}
+	foundFrom := false
+	err = iter.ForEach(func(c *object.Commit) error {
+		if from != "" && c.Hash.String() == from {
+			foundFrom = true
+			return storer.ErrStop
+		}
+		if len(changelog.Entries) >= maxChangelogCommits {
+			changelog.Truncated = true
+			return storer.ErrStop
+		}
+		if c.NumParents() > 1 {
+			return nil
+		}
+		typ, scope, subject, breaking := parseConventionalCommit(c.Message)
+		changelog.Entries = append(changelog.Entries, ChangelogEntry{
+			Hash:     c.Hash.String(),
+			Type:     typ,
+			Scope:    scope,
+			Subject:  subject,
+			Breaking: breaking,
+			Author:   c.Author.Name,
+			Email:    c.Author.Email,
+			Time:     c.Author.When,
+		})
+		authors[c.Author.Name] = true
+		return nil
+	})
+	if err != nil {
+		return changelog, err
+	}
+	if from != "" && !foundFrom {
+		// 上一次发布的提交不在当前历史中，例如被强制推送覆盖
+		changelog.FromCommit = ""
+	}
+	for author := range authors {
+		changelog.Authors = append(changelog.Authors, author)
+	}
+	sort.Strings(changelog.Authors)
+	changelog.Markdown = renderChangelog(changelog)
+	return changelog, nil
+}
+
+// renderChangelog 按分类输出 Markdown
+func renderChangelog(changelog Changelog) string {
+	grouped := map[string][]ChangelogEntry{}
+	for _, entry := range changelog.Entries {
+		key := entry.Type
+		if entry.Breaking {
+			key = "breaking"
+		}
+		grouped[key] = append(grouped[key], entry)
+	}
+
+	var b strings.Builder
+	for _, category := range changelogCategories {
+		entries := grouped[category.Type]
+		if len(entries) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "### %s\n\n", category.Title)
+		for _, entry := range entries {
+			b.WriteString("- ")
+			if entry.Scope != "" {
+				fmt.Fprintf(&b, "**%s:** ", entry.Scope)
+			}
+			fmt.Fprintf(&b, "%s (%s, %s)\n", entry.Subject, shortCommit(entry.Hash), entry.Author)
+		}
+		b.WriteString("\n")
+	}
+	if changelog.Truncated {
+		fmt.Fprintf(&b, "_Only the latest %d commits are listed._\n", maxChangelogCommits)
+	}
+	return b.String()
+}
+
+// previousReleaseCommit 返回应用上一次成功发布的提交，指定环境时只看该环境
+func previousReleaseCommit(db *gorm.DB, app, environment string) (string, error) {
+	query := db.Where("app = ? AND status = ? AND commit <> ''", app, ReleaseSucceeded)
+	if environment != "" {
+		query = query.Where("environment = ?", environment)
+	}
+	var release Release
+	err := query.Order("id desc").First(&release).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	return release.Commit, err
+}
+
+// ChangelogActivity 生成自上一次成功发布以来的变更日志并保存，LocalPath 不是 Git 仓库时返回空日志
+func ChangelogActivity(ctx context.Context, config Config) (Changelog, error) {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+
+	repo, err := git.PlainOpen(config.LocalPath)
+	if err != nil {
+		logs.Printf("Skipping changelog, %s is not a git repository: %v", config.LocalPath, err)
+		return Changelog{}, nil
+	}
+	to := config.Commit
+	if to == "" {
+		head, err := repo.Head()
+		if err != nil {
+			return Changelog{}, fmt.Errorf("resolve HEAD error: %v", err)
+		}
+		to = head.Hash().String()
+	}
+
+	db := shared.GetDB()
+	from, err := previousReleaseCommit(db, config.AppName(), config.Environment)
+	if err != nil {
+		return Changelog{}, fmt.Errorf("load previous release error: %v", err)
+	}
+	logs.Printf("Generating changelog %s..%s", shortCommit(from), shortCommit(to))
+	reportProgress(ctx, "generating changelog since %s", shortCommit(from))
+
+	changelog, err := buildChangelog(repo, from, to)
+	if err != nil {
+		return changelog, fmt.Errorf("walk history error: %v", err)
+	}
+	changelog.App = config.AppName()
+	changelog.Version = config.Version
+	logs.Printf("%d commits by %d authors\n%s", len(changelog.Entries), len(changelog.Authors), changelog.Markdown)
+
+	err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "app"}, {Name: "version"}},
+		DoUpdates: clause.AssignmentColumns([]string{"from_commit", "to_commit", "entries", "authors", "truncated", "markdown"}),
+	}).Create(&changelog).Error
+	if err != nil {
+		return changelog, fmt.Errorf("record changelog error: %v", err)
+	}
+	return changelog, nil
+}
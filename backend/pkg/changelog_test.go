@@ -0,0 +1,101 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestParseConventionalCommit(t *testing.T) {
+	tests := []struct {
+		message  string
+		typ      string
+		scope    string
+		subject  string
+		breaking bool
+	}{
+		{"feat(api): add promote endpoint", "feat", "api", "add promote endpoint", false},
+		{"fix: handle empty hosts\n\nlonger body", "fix", "", "handle empty hosts", false},
+		{"refactor!: drop legacy deploy mode", "refactor", "", "drop legacy deploy mode", true},
+		{"feat: new config format\n\nBREAKING CHANGE: old files are rejected", "feat", "", "new config format", true},
+		{"Feat(ui): capitalised type", "feat", "ui", "capitalised type", false},
+		{"wip: something", "other", "", "something", false},
+		{"Merge branch 'main'", "other", "", "Merge branch 'main'", false},
+	}
+
+	for _, tt := range tests {
+		typ, scope, subject, breaking := parseConventionalCommit(tt.message)
+		if typ != tt.typ || scope != tt.scope || subject != tt.subject || breaking != tt.breaking {
+			t.Errorf("parseConventionalCommit(%q) = %q, %q, %q, %v, want %q, %q, %q, %v",
+				tt.message, typ, scope, subject, breaking, tt.typ, tt.scope, tt.subject, tt.breaking)
+		}
+	}
+}
+
+func TestBuildChangelog(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := func(message, author string) string {
+		if err := os.WriteFile(filepath.Join(dir, "README"), []byte(message), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := worktree.Add("README"); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := worktree.Commit(message, &git.CommitOptions{
+			Author: &object.Signature{Name: author, Email: author + "@example.com", When: time.Now()},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash.String()
+	}
+
+	released := commit("chore: initial import", "alice")
+	commit("feat(api): add promote endpoint", "bob")
+	commit("fix: handle empty hosts", "alice")
+	head := commit("refactor!: drop legacy deploy mode", "carol")
+
+	changelog, err := buildChangelog(repo, released, head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changelog.Entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(changelog.Entries))
+	}
+	if changelog.FromCommit != released || changelog.Truncated {
+		t.Errorf("FromCommit = %q, Truncated = %v", changelog.FromCommit, changelog.Truncated)
+	}
+	if got := strings.Join(changelog.Authors, ","); got != "alice,bob,carol" {
+		t.Errorf("Authors = %q", got)
+	}
+	for _, want := range []string{"### Breaking Changes", "### Features", "**api:** add promote endpoint", "### Bug Fixes"} {
+		if !strings.Contains(changelog.Markdown, want) {
+			t.Errorf("Markdown missing %q:\n%s", want, changelog.Markdown)
+		}
+	}
+	if strings.Contains(changelog.Markdown, "initial import") {
+		t.Errorf("Markdown includes the released commit:\n%s", changelog.Markdown)
+	}
+
+	// 上一次发布的提交不在历史中时列出全部提交
+	changelog, err = buildChangelog(repo, strings.Repeat("0", 40), head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changelog.Entries) != 4 || changelog.FromCommit != "" {
+		t.Errorf("got %d entries from %q, want 4 from \"\"", len(changelog.Entries), changelog.FromCommit)
+	}
+}
@@ -0,0 +1,423 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	gitHttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"go.temporal.io/sdk/temporal"
+	"gopkg.in/yaml.v3"
+
+	"temporal-aone/backend/shared"
+)
+
+// 配置发布后的生效方式
+const (
+	ReloadSignal  = "signal"
+	ReloadCommand = "command"
+	ReloadRestart = "restart"
+)
+
+// ErrTypeInvalidConfig 配置文件校验失败，重试没有意义
+const ErrTypeInvalidConfig = "InvalidConfigError"
+
+// ConfigReleaseSpec 配置发布配置，零值字段使用默认值
+type ConfigReleaseSpec struct {
+	// 仓库中的分支、标签或提交
+	Ref string
+	// 仓库中的配置文件路径，为空时使用 Config.ConfigFilePath
+	Path string
+	// 仓库中的 JSON Schema 文件路径，可选
+	SchemaPath string
+	// 主机上的配置文件路径
+	RemotePath string
+
+	ReloadMode    string
+	ReloadSignal  string
+	PIDFile       string
+	ReloadCommand string
+}
+
+// ConfigFile 从仓库中读取的配置文件，Environment 不为空时 Content 是未渲染的模板
+type ConfigFile struct {
+	Path    string
+	Ref     string
+	Commit  string
+	Content string
+	Schema  string
+	// 渲染模板使用的应用和环境；渲染结果含敏感变量，只在活动内渲染，不进入工作流历史
+	App         string
+	Environment string
+}
+
+// ConfigDiff 主机上的配置与待发布配置的差异
+type ConfigDiff struct {
+	Host    string
+	Changed bool
+	Diff    string
+}
+
+func (s ConfigReleaseSpec) withDefaults(config Config) ConfigReleaseSpec {
+	if s.Ref == "" {
+		s.Ref = config.Tag
+	}
+	if s.Ref == "" {
+		s.Ref = "HEAD"
+	}
+	if s.Path == "" {
+		s.Path = config.ConfigFilePath
+	}
+	if s.RemotePath == "" {
+		s.RemotePath = path.Join(config.ECSUploadPath, path.Base(s.Path))
+	}
+	if s.ReloadMode == "" {
+		s.ReloadMode = ReloadSignal
+	}
+	if s.ReloadSignal == "" {
+		s.ReloadSignal = "HUP"
+	}
+	if s.PIDFile == "" {
+		s.PIDFile = config.Handoff.withDefaults(config).PIDFile
+	}
+	return s
+}
+
+// renderConfigFile 用环境变量渲染配置模板，未指定环境时原样返回
+func renderConfigFile(file ConfigFile, vars map[string]string) (ConfigFile, error) {
+	if file.Environment == "" {
+		return file, nil
+	}
+	content, err := renderTemplate(path.Base(file.Path), file.Content, vars)
+	if err != nil {
+		return file, temporal.NewNonRetryableApplicationError(fmt.Sprintf("render %s for %s error: %v", file.Path, file.Environment, err), ErrTypeRenderConfig, nil)
+	}
+	file.Content = content
+	return file, nil
+}
+
+// renderedConfigFile 读取环境变量渲染配置文件，同时返回日志和差异中需要脱敏的值
+func renderedConfigFile(file ConfigFile) (ConfigFile, []string, error) {
+	if file.Environment == "" {
+		return file, nil, nil
+	}
+	vars, secrets, err := loadEnvironmentVariables(shared.GetDB(), file.App, file.Environment)
+	if err != nil {
+		return file, nil, fmt.Errorf("load variables for %s/%s error: %v", file.App, file.Environment, err)
+	}
+	file, err = renderConfigFile(file, vars)
+	return file, secrets, err
+}
+
+// FetchConfigActivity 从仓库指定版本读取配置文件和可选的 Schema，指定环境时按环境变量渲染
+func FetchConfigActivity(ctx context.Context, config Config) (ConfigFile, error) {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+
+	spec := config.ConfigRelease.withDefaults(config)
+	file := ConfigFile{Path: spec.Path, Ref: spec.Ref}
+	logs.Printf("Fetching %s@%s from %s", spec.Path, spec.Ref, config.RepoURL)
+	reportProgress(ctx, "fetching %s@%s", spec.Path, spec.Ref)
+
+	repo, err := git.CloneContext(ctx, memory.NewStorage(), nil, &git.CloneOptions{
+		URL: config.RepoURL,
+		Auth: &gitHttp.BasicAuth{
+			Username: config.UserName,
+			Password: config.Token,
+		},
+		Progress: logs,
+	})
+	if err != nil {
+		return file, fmt.Errorf("clone %s error: %v", config.RepoURL, err)
+	}
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(spec.Ref))
+	if err != nil {
+		// 分支只存在于 origin 下
+		hash, err = repo.ResolveRevision(plumbing.Revision("origin/" + spec.Ref))
+	}
+	if err != nil {
+		return file, temporal.NewNonRetryableApplicationError(fmt.Sprintf("resolve ref %s error: %v", spec.Ref, err), ErrTypeInvalidConfig, nil)
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return file, err
+	}
+	file.Commit = commit.Hash.String()
+
+	readFile := func(name string) (string, error) {
+		f, err := commit.File(name)
+		if err != nil {
+			return "", temporal.NewNonRetryableApplicationError(fmt.Sprintf("read %s at %s error: %v", name, spec.Ref, err), ErrTypeInvalidConfig, nil)
+		}
+		return f.Contents()
+	}
+	if file.Content, err = readFile(spec.Path); err != nil {
+		return file, err
+	}
+	if spec.SchemaPath != "" {
+		if file.Schema, err = readFile(spec.SchemaPath); err != nil {
+			return file, err
+		}
+	}
+
+	logs.Printf("Fetched %s at commit %s (%d bytes)", spec.Path, file.Commit, len(file.Content))
+
+	if config.Environment != "" {
+		file.App, file.Environment = config.AppName(), config.Environment
+		// 先渲染一次，模板错误或缺少变量时在校验和对比之前失败
+		if _, _, err := renderedConfigFile(file); err != nil {
+			return file, err
+		}
+		logs.Printf("Rendered %s for %s/%s", spec.Path, file.App, file.Environment)
+	}
+	return file, nil
+}
+
+// ValidateConfigActivity 校验渲染后的配置文件语法，提供 Schema 时同时校验结构
+func ValidateConfigActivity(ctx context.Context, file ConfigFile) error {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+
+	rendered, secrets, err := renderedConfigFile(file)
+	if err != nil {
+		return err
+	}
+	if err := validateConfigFile(rendered); err != nil {
+		// 校验错误可能带有配置中的值
+		msg := redactSecrets(err.Error(), secrets)
+		logs.Printf("Validation of %s failed: %s", file.Path, msg)
+		return temporal.NewNonRetryableApplicationError(fmt.Sprintf("invalid config %s: %s", file.Path, msg), ErrTypeInvalidConfig, nil)
+	}
+	logs.Printf("%s is valid", file.Path)
+	return nil
+}
+
+func validateConfigFile(file ConfigFile) error {
+	doc, err := parseConfigDocument(file.Path, file.Content)
+	if err != nil {
+		return err
+	}
+	if file.Schema == "" {
+		return nil
+	}
+	if doc == nil {
+		return fmt.Errorf("schema validation needs a yaml or json file")
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(file.Schema), &schema); err != nil {
+		return fmt.Errorf("invalid schema: %v", err)
+	}
+	return validateSchema(doc, schema, "$")
+}
+
+// parseConfigDocument 按扩展名解析 YAML/JSON，其它格式不做语法校验并返回 nil
+func parseConfigDocument(name, content string) (interface{}, error) {
+	var doc interface{}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		if err := json.Unmarshal([]byte(content), &doc); err != nil {
+			return nil, fmt.Errorf("json syntax error: %v", err)
+		}
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+			return nil, fmt.Errorf("yaml syntax error: %v", err)
+		}
+	default:
+		return nil, nil
+	}
+	return doc, nil
+}
+
+// validateSchema 支持 JSON Schema 的常用子集：type、required、properties、items、enum
+func validateSchema(value interface{}, schema map[string]interface{}, at string) error {
+	if t, ok := schema["type"].(string); ok && !schemaTypeMatches(value, t) {
+		return fmt.Errorf("%s: expected %s", at, t)
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", at, value, enum)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, key := range required {
+				if _, ok := v[fmt.Sprint(key)]; !ok {
+					return fmt.Errorf("%s: missing required field %v", at, key)
+				}
+			}
+		}
+		if props, ok := schema["properties"].(map[string]interface{}); ok {
+			keys := make([]string, 0, len(props))
+			for key := range props {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				child, ok := v[key]
+				propSchema, isMap := props[key].(map[string]interface{})
+				if !ok || !isMap {
+					continue
+				}
+				if err := validateSchema(child, propSchema, at+"."+key); err != nil {
+					return err
+				}
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				if err := validateSchema(item, items, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func schemaTypeMatches(value interface{}, t string) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "integer":
+		switch n := value.(type) {
+		case int:
+			return true
+		case float64:
+			return n == float64(int64(n))
+		}
+		return false
+	case "number":
+		switch value.(type) {
+		case int, float64:
+			return true
+		}
+		return false
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+// DiffConfigActivity 比较主机上当前的配置和渲染后待发布的配置，差异中的敏感变量脱敏
+func DiffConfigActivity(ctx context.Context, config Config, file ConfigFile) (ConfigDiff, error) {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+
+	rendered, secrets, err := renderedConfigFile(file)
+	if err != nil {
+		return ConfigDiff{Host: config.ECSServer}, err
+	}
+	spec := config.ConfigRelease.withDefaults(config)
+	current, err := runRemote(ctx, config, logs, fmt.Sprintf("cat %s 2>/dev/null || true", shellQuote(spec.RemotePath)))
+	if err != nil {
+		return ConfigDiff{Host: config.ECSServer}, err
+	}
+
+	diff := unifiedDiff(config.ECSServer+":"+spec.RemotePath, file.Path+"@"+file.Commit,
+		redactSecrets(current, secrets), redactSecrets(rendered.Content, secrets))
+	// 只改了敏感变量时脱敏后的差异为空，按内容判断是否变化
+	changed := current != rendered.Content
+	if diff == "" && changed {
+		logs.Printf("Only secret values changed on %s", config.ECSServer)
+	} else {
+		logs.Printf("%s", diff)
+	}
+	return ConfigDiff{Host: config.ECSServer, Changed: changed, Diff: diff}, nil
+}
+
+// DistributeConfigActivity 渲染后原子地写入配置文件，旧配置保存为 .bak 以便回滚；
+// 之前没有配置文件时删除遗留的 .bak，回滚时据此删除新文件
+func DistributeConfigActivity(ctx context.Context, config Config, file ConfigFile) error {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+
+	rendered, _, err := renderedConfigFile(file)
+	if err != nil {
+		return err
+	}
+	spec := config.ConfigRelease.withDefaults(config)
+	remote := shellQuote(spec.RemotePath)
+	tmp := shellQuote(spec.RemotePath + ".tmp")
+	backup := shellQuote(spec.RemotePath + ".bak")
+	script := fmt.Sprintf("mkdir -p %s && printf '%%s' %s > %s && (if test -f %s; then cp -p %s %s; else rm -f %s; fi) && mv %s %s",
+		shellQuote(path.Dir(spec.RemotePath)), shellQuote(rendered.Content), tmp,
+		remote, remote, backup, backup, tmp, remote)
+	logs.Printf("Writing %s on %s", spec.RemotePath, config.ECSServer)
+	reportProgress(ctx, "writing %s", spec.RemotePath)
+	_, err = runRemote(ctx, config, logs, script)
+	return err
+}
+
+// restoreConfigScript 有 .bak 时恢复旧配置并输出 restored，首次发布没有旧配置时删除新写入的文件
+func restoreConfigScript(remotePath string) string {
+	remote := shellQuote(remotePath)
+	backup := shellQuote(remotePath + ".bak")
+	return fmt.Sprintf("if test -f %s; then mv %s %s && echo restored; else rm -f %s; fi", backup, backup, remote, remote)
+}
+
+// RestoreConfigActivity 用 .bak 恢复发布前的配置，返回是否有旧配置被恢复
+func RestoreConfigActivity(ctx context.Context, config Config) (bool, error) {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+
+	spec := config.ConfigRelease.withDefaults(config)
+	logs.Printf("Restoring %s on %s", spec.RemotePath, config.ECSServer)
+	output, err := runRemote(ctx, config, logs, restoreConfigScript(spec.RemotePath))
+	if err != nil {
+		return false, err
+	}
+	restored := strings.TrimSpace(output) == "restored"
+	if !restored {
+		logs.Printf("No previous %s on %s, removed the new config", spec.RemotePath, config.ECSServer)
+	}
+	return restored, nil
+}
+
+// ReloadConfigActivity 通过信号或命令让应用重新加载配置
+func ReloadConfigActivity(ctx context.Context, config Config) error {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+
+	spec := config.ConfigRelease.withDefaults(config)
+	var command string
+	switch spec.ReloadMode {
+	case ReloadSignal:
+		command = fmt.Sprintf("kill -%s $(cat %s)", spec.ReloadSignal, shellQuote(spec.PIDFile))
+	case ReloadCommand:
+		command = spec.ReloadCommand
+	default:
+		return temporal.NewNonRetryableApplicationError(fmt.Sprintf("unsupported reload mode %q", spec.ReloadMode), ErrTypeInvalidConfig, nil)
+	}
+	logs.Printf("Reloading on %s: %s", config.ECSServer, command)
+	reportProgress(ctx, "reloading config on %s", config.ECSServer)
+	_, err := runRemote(ctx, config, logs, command)
+	return err
+}
@@ -0,0 +1,76 @@
+package pkg
+
+import "testing"
+
+const testSchema = `{
+	"type": "object",
+	"required": ["server"],
+	"properties": {
+		"server": {
+			"type": "object",
+			"required": ["port"],
+			"properties": {
+				"port": {"type": "integer"},
+				"mode": {"enum": ["debug", "release"]}
+			}
+		},
+		"hosts": {"type": "array", "items": {"type": "string"}}
+	}
+}`
+
+func TestValidateConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    ConfigFile
+		wantErr bool
+	}{
+		{name: "valid yaml", file: ConfigFile{Path: "config.yaml", Content: "server:\n  port: 3000\n  mode: release\nhosts: [a, b]\n", Schema: testSchema}},
+		{name: "valid json", file: ConfigFile{Path: "config.json", Content: `{"server": {"port": 3000}}`, Schema: testSchema}},
+		{name: "yaml syntax error", file: ConfigFile{Path: "config.yml", Content: "server:\n  port: [3000\n"}, wantErr: true},
+		{name: "json syntax error", file: ConfigFile{Path: "config.json", Content: `{"server":`}, wantErr: true},
+		{name: "missing required", file: ConfigFile{Path: "config.yaml", Content: "hosts: []\n", Schema: testSchema}, wantErr: true},
+		{name: "wrong type", file: ConfigFile{Path: "config.yaml", Content: "server:\n  port: \"3000\"\n", Schema: testSchema}, wantErr: true},
+		{name: "not in enum", file: ConfigFile{Path: "config.yaml", Content: "server:\n  port: 1\n  mode: test\n", Schema: testSchema}, wantErr: true},
+		{name: "array item type", file: ConfigFile{Path: "config.yaml", Content: "server:\n  port: 1\nhosts: [1]\n", Schema: testSchema}, wantErr: true},
+		{name: "unknown format skips syntax check", file: ConfigFile{Path: "app.ini", Content: "[server"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateConfigFile(tt.file); (err != nil) != tt.wantErr {
+				t.Errorf("validateConfigFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRenderConfigFile(t *testing.T) {
+	vars := map[string]string{"PORT": "8080"}
+	tests := []struct {
+		name    string
+		file    ConfigFile
+		want    string
+		wantErr bool
+	}{
+		{name: "no environment keeps the file", file: ConfigFile{Path: "config.yaml", Content: "port: {{ .PORT }}\n"}, want: "port: {{ .PORT }}\n"},
+		{name: "renders for the environment", file: ConfigFile{Path: "config.yaml", Content: "port: {{ .PORT }}\n", Environment: "prod"}, want: "port: 8080\n"},
+		{name: "missing variable", file: ConfigFile{Path: "config.yaml", Content: "host: {{ .HOST }}\n", Environment: "prod"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderConfigFile(tt.file, vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renderConfigFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.Content != tt.want {
+				t.Errorf("renderConfigFile() = %q, want %q", got.Content, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestoreConfigScript(t *testing.T) {
+	want := "if test -f '/opt/app/config.yaml.bak'; then mv '/opt/app/config.yaml.bak' '/opt/app/config.yaml' && echo restored; else rm -f '/opt/app/config.yaml'; fi"
+	if got := restoreConfigScript("/opt/app/config.yaml"); got != want {
+		t.Errorf("restoreConfigScript() = %s, want %s", got, want)
+	}
+}
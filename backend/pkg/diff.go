@@ -0,0 +1,111 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// diff 上下文行数
+const diffContext = 3
+
+type diffLine struct {
+	kind byte // ' '、'-'、'+'
+	text string
+}
+
+// diffLines 按行比较两段文本
+func diffLines(oldText, newText string) []diffLine {
+	dmp := diffmatchpatch.New()
+	a, b, lines := dmp.DiffLinesToChars(oldText, newText)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(a, b, false), lines)
+
+	var result []diffLine
+	for _, d := range diffs {
+		kind := byte(' ')
+		switch d.Type {
+		case diffmatchpatch.DiffDelete:
+			kind = '-'
+		case diffmatchpatch.DiffInsert:
+			kind = '+'
+		}
+		for _, line := range strings.SplitAfter(d.Text, "\n") {
+			if line != "" {
+				result = append(result, diffLine{kind: kind, text: strings.TrimSuffix(line, "\n")})
+			}
+		}
+	}
+	return result
+}
+
+// unifiedDiff 生成统一格式的 diff，内容相同时返回空字符串
+func unifiedDiff(oldLabel, newLabel, oldText, newText string) string {
+	lines := diffLines(oldText, newText)
+
+	var b strings.Builder
+	for i := 0; i < len(lines); {
+		for i < len(lines) && lines[i].kind == ' ' {
+			i++
+		}
+		if i == len(lines) {
+			break
+		}
+
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(lines) {
+			if lines[end].kind != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(lines) && lines[next].kind == ' ' {
+				next++
+			}
+			// 相邻修改之间的相同行较少时合并为一个块
+			if next == len(lines) || next-end > 2*diffContext {
+				end += diffContext
+				if end > len(lines) {
+					end = len(lines)
+				}
+				break
+			}
+			end = next
+		}
+
+		oldStart, newStart := 1, 1
+		for _, l := range lines[:start] {
+			if l.kind != '+' {
+				oldStart++
+			}
+			if l.kind != '-' {
+				newStart++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, l := range lines[start:end] {
+			if l.kind != '+' {
+				oldCount++
+			}
+			if l.kind != '-' {
+				newCount++
+			}
+		}
+
+		if b.Len() == 0 {
+			fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldLabel, newLabel)
+		}
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, l := range lines[start:end] {
+			b.WriteByte(l.kind)
+			b.WriteString(l.text)
+			b.WriteByte('\n')
+		}
+		i = end
+	}
+	return b.String()
+}
@@ -0,0 +1,33 @@
+package pkg
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{name: "identical", old: "a\nb\n", new: "a\nb\n", want: ""},
+		{
+			name: "single change with context",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n",
+			new:  "1\n2\n3\n4\nfive\n6\n7\n8\n",
+			want: "--- old\n+++ new\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "separate hunks",
+			old:  "a\n1\n2\n3\n4\n5\n6\n7\nb\n",
+			new:  "A\n1\n2\n3\n4\n5\n6\n7\nB\n",
+			want: "--- old\n+++ new\n@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n@@ -6,4 +6,4 @@\n 5\n 6\n 7\n-b\n+B\n",
+		},
+		{name: "new file", old: "", new: "x\n", want: "--- old\n+++ new\n@@ -1,0 +1,1 @@\n+x\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("old", "new", tt.old, tt.new); got != tt.want {
+				t.Errorf("unifiedDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrPromotionNotAllowed 版本未在上一个环境发布成功
+var ErrPromotionNotAllowed = errors.New("promotion not allowed")
+
+// Environment 应用的一个部署环境，Position 决定晋级顺序（dev → staging → prod）
+type Environment struct {
+	ID       uint     `gorm:"primaryKey" json:"id"`
+	App      string   `gorm:"size:128;uniqueIndex:idx_env_app_name" json:"app"`
+	Name     string   `gorm:"size:64;uniqueIndex:idx_env_app_name" json:"name"`
+	Position int      `json:"position"`
+	Hosts    []string `gorm:"type:text;serializer:json" json:"hosts"`
+
+	ECSUser           string `gorm:"size:64" json:"ecs_user"`
+	ECSUploadPath     string `gorm:"size:512" json:"ecs_upload_path"`
+	DeployMode        string `gorm:"size:32" json:"deploy_mode"`
+	HealthCheckURL    string `gorm:"size:512" json:"health_check_url"`
+	VersionedReleases bool   `json:"versioned_releases"`
+	KeepReleases      int    `json:"keep_releases"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Apply 用环境的主机和部署策略覆盖配置
+func (e Environment) Apply(config Config) Config {
+	config.App = e.App
+	config.Environment = e.Name
+	config.Hosts = e.Hosts
+	if len(e.Hosts) > 0 {
+		config.ECSServer = e.Hosts[0]
+	}
+	if e.ECSUser != "" {
+		config.ECSUser = e.ECSUser
+	}
+	if e.ECSUploadPath != "" {
+		config.ECSUploadPath = e.ECSUploadPath
+	}
+	if e.DeployMode != "" {
+		config.DeployMode = e.DeployMode
+	}
+	if e.HealthCheckURL != "" {
+		config.HealthCheckURL = e.HealthCheckURL
+	}
+	config.Releases.Enabled = e.VersionedReleases
+	config.Releases.Keep = e.KeepReleases
+	return config
+}
+
+// LoadPipeline 按晋级顺序返回应用的全部环境
+func LoadPipeline(db *gorm.DB, app string) ([]Environment, error) {
+	var envs []Environment
+	err := db.Where("app = ?", app).Order("position, id").Find(&envs).Error
+	return envs, err
+}
+
+// pipelineIndex 返回环境在流水线中的位置，不存在时返回 -1
+func pipelineIndex(envs []Environment, name string) int {
+	for i, env := range envs {
+		if env.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// NextEnvironment 返回 name 之后的环境
+func NextEnvironment(envs []Environment, name string) (Environment, bool) {
+	i := pipelineIndex(envs, name)
+	if i < 0 || i+1 >= len(envs) {
+		return Environment{}, false
+	}
+	return envs[i+1], true
+}
+
+// PreviousEnvironment 返回 name 之前的环境
+func PreviousEnvironment(envs []Environment, name string) (Environment, bool) {
+	i := pipelineIndex(envs, name)
+	if i <= 0 {
+		return Environment{}, false
+	}
+	return envs[i-1], true
+}
+
+// LatestRelease 返回版本在环境中最近一次成功的发布
+func LatestRelease(db *gorm.DB, app, environment, version string) (Release, error) {
+	var release Release
+	err := db.Where("app = ? AND environment = ? AND version = ? AND status = ?", app, environment, version, ReleaseSucceeded).
+		Order("id desc").First(&release).Error
+	return release, err
+}
+
+// CheckPromotion 检查版本能否发布到 target：版本必须已在紧邻的上一个环境发布成功，返回可复用构建产物的发布记录
+func CheckPromotion(db *gorm.DB, envs []Environment, target Environment, version string) (Release, error) {
+	previous, ok := PreviousEnvironment(envs, target.Name)
+	if !ok {
+		return Release{}, fmt.Errorf("%w: %s is the first environment of %s", ErrPromotionNotAllowed, target.Name, target.App)
+	}
+
+	release, err := LatestRelease(db, target.App, previous.Name, version)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return release, fmt.Errorf("%w: %s has not been released to %s successfully", ErrPromotionNotAllowed, version, previous.Name)
+	}
+	return release, err
+}
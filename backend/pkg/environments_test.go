@@ -0,0 +1,64 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPipelineNeighbours(t *testing.T) {
+	envs := []Environment{{Name: "dev"}, {Name: "staging"}, {Name: "prod"}}
+
+	tests := []struct {
+		name     string
+		next     string
+		nextOK   bool
+		previous string
+		prevOK   bool
+	}{
+		{"dev", "staging", true, "", false},
+		{"staging", "prod", true, "dev", true},
+		{"prod", "", false, "staging", true},
+		{"unknown", "", false, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, ok := NextEnvironment(envs, tt.name)
+			if ok != tt.nextOK || next.Name != tt.next {
+				t.Errorf("NextEnvironment() = %q, %v, want %q, %v", next.Name, ok, tt.next, tt.nextOK)
+			}
+			previous, ok := PreviousEnvironment(envs, tt.name)
+			if ok != tt.prevOK || previous.Name != tt.previous {
+				t.Errorf("PreviousEnvironment() = %q, %v, want %q, %v", previous.Name, ok, tt.previous, tt.prevOK)
+			}
+		})
+	}
+}
+
+func TestEnvironmentApply(t *testing.T) {
+	env := Environment{
+		App:               "demo",
+		Name:              "staging",
+		Hosts:             []string{"10.0.1.1", "10.0.1.2"},
+		ECSUser:           "deploy",
+		VersionedReleases: true,
+		KeepReleases:      3,
+	}
+	config := env.Apply(Config{ECSUser: "root", ECSUploadPath: "/opt/demo", Version: "v1.2.0"})
+
+	if config.Environment != "staging" || config.App != "demo" {
+		t.Errorf("environment = %q/%q", config.App, config.Environment)
+	}
+	if config.ECSServer != "10.0.1.1" || !reflect.DeepEqual(config.Hosts, env.Hosts) {
+		t.Errorf("hosts = %q %v", config.ECSServer, config.Hosts)
+	}
+	if config.ECSUser != "deploy" || config.ECSUploadPath != "/opt/demo" {
+		t.Errorf("ssh target = %s:%s", config.ECSUser, config.ECSUploadPath)
+	}
+	if !config.Releases.Enabled || config.Releases.Keep != 3 {
+		t.Errorf("releases = %+v", config.Releases)
+	}
+	if config.Version != "v1.2.0" {
+		t.Errorf("version = %q", config.Version)
+	}
+}
@@ -0,0 +1,320 @@
+package pkg
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	gitHttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"go.temporal.io/sdk/temporal"
+)
+
+// 支持的 Git 托管平台
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+	ProviderGitea  = "gitea"
+)
+
+// ErrUnsupportedEvent 不需要处理的 webhook 事件，例如 ping 或 issue
+var ErrUnsupportedEvent = errors.New("unsupported webhook event")
+
+// Application 应用的仓库和构建参数，webhook 通过仓库地址找到应用
+type Application struct {
+	ID      uint   `gorm:"primaryKey" json:"id"`
+	Name    string `gorm:"size:128;uniqueIndex" json:"name"`
+	RepoURL string `gorm:"size:512" json:"repo_url"`
+	// 规范化后的仓库地址，用于匹配 webhook 中的各种地址形式
+	RepoKey  string `gorm:"size:512;index" json:"-"`
+	UserName string `gorm:"size:128" json:"user_name"`
+	Token    string `gorm:"size:512" json:"-"`
+	// webhook 签名密钥
+	WebhookSecret string `gorm:"size:255" json:"-"`
+
+	// 触发构建的分支，为空时所有分支都触发；BuildTags 为 true 时标签也触发
+	Branches  []string `gorm:"type:text;serializer:json" json:"branches"`
+	BuildTags bool     `json:"build_tags"`
+
+	BinaryPath     string `gorm:"size:512" json:"binary_path"`
+	ConfigFilePath string `gorm:"size:512" json:"config_file_path"`
+	ECSUploadPath  string `gorm:"size:512" json:"ecs_upload_path"`
+	ECSUser        string `gorm:"size:64" json:"ecs_user"`
+	ECSServer      string `gorm:"size:255" json:"ecs_server"`
+	// 构建后上传到的环境，为空时上传到 ECSServer
+	Environment string `gorm:"size:64" json:"environment"`
+
+	// 分支构建的版本策略，为空时使用短提交号；TagReleases 为 true 时构建成功后推送版本标签
+	VersionStrategy string `gorm:"size:32" json:"version_strategy"`
+	VersionPrefix   string `gorm:"size:32" json:"version_prefix"`
+	TagReleases     bool   `json:"tag_releases"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PushEvent 各平台 push 和 tag 事件中用到的字段
+type PushEvent struct {
+	Provider string
+	// 事件中出现的仓库地址（https、ssh、网页地址）
+	RepoURLs []string
+	Ref      string
+	Commit   string
+	Pusher   string
+	Deleted  bool
+}
+
+// IsTag 是否为标签事件
+func (e PushEvent) IsTag() bool {
+	return strings.HasPrefix(e.Ref, "refs/tags/")
+}
+
+// RefName 去掉 refs/heads/ 或 refs/tags/ 前缀的分支名或标签名
+func (e PushEvent) RefName() string {
+	return strings.TrimPrefix(strings.TrimPrefix(e.Ref, "refs/heads/"), "refs/tags/")
+}
+
+// ShouldBuild 根据应用的分支和标签配置判断是否触发构建
+func (a Application) ShouldBuild(event PushEvent) bool {
+	if event.Deleted {
+		return false
+	}
+	if event.IsTag() {
+		return a.BuildTags
+	}
+	if len(a.Branches) == 0 {
+		return true
+	}
+	for _, branch := range a.Branches {
+		if branch == event.RefName() {
+			return true
+		}
+	}
+	return false
+}
+
+// Versioning 返回应用的版本策略
+func (a Application) Versioning() VersioningSpec {
+	return VersioningSpec{Strategy: a.VersionStrategy, Prefix: a.VersionPrefix, Tag: a.TagReleases}
+}
+
+// BuildConfig 返回由 push 事件触发构建时的工作流配置，标签事件以标签名作为版本号
+func (a Application) BuildConfig(event PushEvent) Config {
+	version := event.RefName()
+	versioning := VersioningSpec{Strategy: VersionExplicit}
+	if !event.IsTag() {
+		version = shortCommit(event.Commit)
+		if a.VersionStrategy != "" {
+			version = ""
+			versioning = a.Versioning()
+		}
+	}
+	return Config{
+		App:            a.Name,
+		RepoURL:        a.RepoURL,
+		UserName:       a.UserName,
+		Token:          a.Token,
+		Tag:            event.RefName(),
+		Commit:         event.Commit,
+		Version:        version,
+		Versioning:     versioning,
+		BinaryPath:     a.BinaryPath,
+		ConfigFilePath: a.ConfigFilePath,
+		ECSUploadPath:  a.ECSUploadPath,
+		ECSUser:        a.ECSUser,
+		ECSServer:      a.ECSServer,
+		Environment:    a.Environment,
+		Actor:          event.Pusher,
+		Notify: NotifySpec{
+			CommitStatus: true,
+			GitProvider:  event.Provider,
+		},
+	}
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
+// DetectProvider 根据请求头判断 webhook 来源
+func DetectProvider(header http.Header) string {
+	switch {
+	case header.Get("X-Gitea-Event") != "":
+		return ProviderGitea
+	case header.Get("X-GitHub-Event") != "":
+		return ProviderGitHub
+	case header.Get("X-Gitlab-Event") != "":
+		return ProviderGitLab
+	}
+	return ""
+}
+
+// VerifyWebhookSignature 校验 webhook 签名：GitHub 和 Gitea 为 body 的 HMAC-SHA256，GitLab 为明文令牌
+func VerifyWebhookSignature(provider, secret string, header http.Header, body []byte) bool {
+	if secret == "" {
+		return false
+	}
+	switch provider {
+	case ProviderGitHub:
+		return hmacEqual(secret, body, strings.TrimPrefix(header.Get("X-Hub-Signature-256"), "sha256="))
+	case ProviderGitea:
+		return hmacEqual(secret, body, header.Get("X-Gitea-Signature"))
+	case ProviderGitLab:
+		return subtle.ConstantTimeCompare([]byte(secret), []byte(header.Get("X-Gitlab-Token"))) == 1
+	}
+	return false
+}
+
+func hmacEqual(secret string, body []byte, signature string) bool {
+	given, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), given)
+}
+
+// ParsePushEvent 解析 push 和 tag 事件，其它事件返回 ErrUnsupportedEvent
+func ParsePushEvent(provider string, header http.Header, body []byte) (PushEvent, error) {
+	event := PushEvent{Provider: provider}
+	switch provider {
+	case ProviderGitHub, ProviderGitea:
+		name := header.Get("X-GitHub-Event")
+		if provider == ProviderGitea {
+			name = header.Get("X-Gitea-Event")
+		}
+		if name != "push" {
+			return event, fmt.Errorf("%w: %s", ErrUnsupportedEvent, name)
+		}
+		var payload struct {
+			Ref        string `json:"ref"`
+			After      string `json:"after"`
+			Deleted    bool   `json:"deleted"`
+			Repository struct {
+				CloneURL string `json:"clone_url"`
+				SSHURL   string `json:"ssh_url"`
+				HTMLURL  string `json:"html_url"`
+			} `json:"repository"`
+			Pusher struct {
+				Name     string `json:"name"`
+				Username string `json:"username"`
+			} `json:"pusher"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return event, fmt.Errorf("invalid %s payload: %v", provider, err)
+		}
+		event.Ref = payload.Ref
+		event.Commit = payload.After
+		event.Deleted = payload.Deleted || strings.Trim(payload.After, "0") == ""
+		event.RepoURLs = []string{payload.Repository.CloneURL, payload.Repository.SSHURL, payload.Repository.HTMLURL}
+		event.Pusher = payload.Pusher.Name
+		if event.Pusher == "" {
+			event.Pusher = payload.Pusher.Username
+		}
+	case ProviderGitLab:
+		name := header.Get("X-Gitlab-Event")
+		if name != "Push Hook" && name != "Tag Push Hook" {
+			return event, fmt.Errorf("%w: %s", ErrUnsupportedEvent, name)
+		}
+		var payload struct {
+			Ref         string `json:"ref"`
+			After       string `json:"after"`
+			CheckoutSHA string `json:"checkout_sha"`
+			UserName    string `json:"user_username"`
+			Project     struct {
+				HTTPURL string `json:"git_http_url"`
+				SSHURL  string `json:"git_ssh_url"`
+				WebURL  string `json:"web_url"`
+			} `json:"project"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return event, fmt.Errorf("invalid gitlab payload: %v", err)
+		}
+		event.Ref = payload.Ref
+		event.Commit = payload.CheckoutSHA
+		if event.Commit == "" {
+			event.Commit = payload.After
+		}
+		event.Deleted = strings.Trim(event.Commit, "0") == ""
+		event.RepoURLs = []string{payload.Project.HTTPURL, payload.Project.SSHURL, payload.Project.WebURL}
+		event.Pusher = payload.UserName
+	default:
+		return event, fmt.Errorf("%w: unknown provider", ErrUnsupportedEvent)
+	}
+	if event.Ref == "" {
+		return event, errors.New("payload has no ref")
+	}
+	return event, nil
+}
+
+// NormalizeRepoURL 将 https、ssh 和 scp 形式的仓库地址统一为 host/owner/repo
+func NormalizeRepoURL(repoURL string) string {
+	s := strings.TrimSpace(repoURL)
+	if s == "" {
+		return ""
+	}
+	// git@host:owner/repo.git
+	if !strings.Contains(s, "://") {
+		if at := strings.Index(s, "@"); at >= 0 {
+			s = s[at+1:]
+		}
+		s = strings.Replace(s, ":", "/", 1)
+	} else if u, err := url.Parse(s); err == nil {
+		s = u.Hostname() + u.Path
+	}
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "/"), ".git")
+	return strings.ToLower(s)
+}
+
+// CheckoutActivity 克隆仓库并检出 Config.Commit，返回工作目录
+func CheckoutActivity(ctx context.Context, config Config) (string, error) {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+
+	dir := config.LocalPath
+	if dir == "" {
+		dir = fmt.Sprintf("%s/%s_%s", Workspace, strings.TrimSuffix(getRepoName(config.RepoURL), ".git"), shortCommit(config.Commit))
+	}
+	logs.Printf("Checking out %s@%s into %s", config.RepoURL, config.Commit, dir)
+	reportProgress(ctx, "checking out %s", shortCommit(config.Commit))
+
+	repo, err := git.PlainOpen(dir)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		repo, err = git.PlainCloneContext(ctx, dir, false, &git.CloneOptions{
+			URL: config.RepoURL,
+			Auth: &gitHttp.BasicAuth{
+				Username: config.UserName,
+				Password: config.Token,
+			},
+			Progress: logs,
+		})
+	}
+	if err != nil {
+		return dir, fmt.Errorf("clone %s error: %v", config.RepoURL, err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return dir, err
+	}
+	err = worktree.Checkout(&git.CheckoutOptions{Hash: plumbing.NewHash(config.Commit), Force: true})
+	if err != nil {
+		return dir, temporal.NewNonRetryableApplicationError(fmt.Sprintf("checkout %s error: %v", config.Commit, err), ErrTypeBuild, nil)
+	}
+	logs.Printf("Checked out %s", config.Commit)
+	return dir, nil
+}
@@ -0,0 +1,123 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// DeployModeSocketHandoff 应用使用 tableflip，通过 SIGHUP 触发进程内升级并移交监听套接字
+const DeployModeSocketHandoff = "socket-handoff"
+
+// HandoffSpec 套接字移交重启配置
+type HandoffSpec struct {
+	// tableflip.Options.PIDFile 指定的 PID 文件，新进程 Ready 后会重写该文件
+	PIDFile string
+	// 等待新进程就绪的最长时间
+	Timeout time.Duration
+	// 轮询 PID 文件的间隔
+	PollInterval time.Duration
+}
+
+// HandoffResult 移交前后的进程号
+type HandoffResult struct {
+	OldPID   int
+	NewPID   int
+	Duration time.Duration
+}
+
+func (s HandoffSpec) withDefaults(config Config) HandoffSpec {
+	if s.PIDFile == "" {
+		base := config.ECSUploadPath
+		if base == "" {
+			base = "."
+		}
+		s.PIDFile = path.Join(base, "app.pid")
+	}
+	if s.Timeout <= 0 {
+		s.Timeout = time.Minute
+	}
+	if s.PollInterval <= 0 {
+		s.PollInterval = time.Second
+	}
+	return s
+}
+
+// parsePID 解析 PID 文件内容
+func parsePID(output string) (int, error) {
+	pid, err := strconv.Atoi(strings.TrimSpace(output))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid pid %q", strings.TrimSpace(output))
+	}
+	return pid, nil
+}
+
+// handedOffPID 解析移交后的 PID 文件，文件无效或仍是旧进程时返回 false
+func handedOffPID(output string, oldPID int) (int, bool) {
+	pid, err := parsePID(output)
+	if err != nil || pid == oldPID {
+		return 0, false
+	}
+	return pid, true
+}
+
+// SocketHandoffActivity 向 PID 文件中的进程发送 SIGHUP，等待 PID 文件指向新的存活进程
+func SocketHandoffActivity(ctx context.Context, config Config) (HandoffResult, error) {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+
+	spec := config.Handoff.withDefaults(config)
+	pidFile := shellQuote(spec.PIDFile)
+	start := time.Now()
+
+	output, err := runRemote(ctx, config, logs, "cat "+pidFile)
+	if err != nil {
+		return HandoffResult{}, err
+	}
+	oldPID, err := parsePID(output)
+	if err != nil {
+		return HandoffResult{}, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("read %s on %s: %v", spec.PIDFile, config.ECSServer, err), ErrTypeRemoteCommand, nil)
+	}
+	result := HandoffResult{OldPID: oldPID}
+
+	logs.Printf("Sending SIGHUP to pid %d on %s", oldPID, config.ECSServer)
+	reportProgress(ctx, "sending SIGHUP to pid %d", oldPID)
+	if _, err := runRemote(ctx, config, logs, fmt.Sprintf("kill -HUP %d", oldPID)); err != nil {
+		return result, err
+	}
+
+	// tableflip 的新进程调用 Ready() 后才会写入新的 PID
+	deadline := start.Add(spec.Timeout)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-time.After(spec.PollInterval):
+		}
+
+		output, err := runRemote(ctx, config, logs, fmt.Sprintf("pid=$(cat %s) && kill -0 $pid && echo $pid", pidFile))
+		if err != nil {
+			logs.Printf("new process not ready yet: %v", err)
+			continue
+		}
+		newPID, ok := handedOffPID(output, oldPID)
+		if !ok {
+			reportProgress(ctx, "waiting for pid %d to hand off", oldPID)
+			continue
+		}
+
+		result.NewPID = newPID
+		result.Duration = time.Since(start)
+		logs.Printf("Handoff complete on %s: pid %d -> %d in %s", config.ECSServer, oldPID, newPID, result.Duration)
+		return result, nil
+	}
+
+	return result, temporal.NewApplicationError(
+		fmt.Sprintf("pid %d on %s did not hand off within %s", oldPID, config.ECSServer, spec.Timeout), ErrTypeRemoteCommand, result)
+}
@@ -0,0 +1,53 @@
+package pkg
+
+import "testing"
+
+func TestParsePID(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    int
+		wantErr bool
+	}{
+		{"pid with newline", "4242\n", 4242, false},
+		{"surrounding spaces", "  17  ", 17, false},
+		{"empty", "", 0, true},
+		{"only whitespace", " \n", 0, true},
+		{"garbage", "cat: app.pid: No such file or directory", 0, true},
+		{"two pids", "12 34", 0, true},
+		{"zero", "0", 0, true},
+		{"negative", "-1", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parsePID(tt.output)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: parsePID() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: parsePID() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHandedOffPID(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   int
+		wantOK bool
+	}{
+		{"new process", "4243\n", 4243, true},
+		{"stale pid of the old process", "4242\n", 0, false},
+		{"pid file being rewritten", "", 0, false},
+		{"garbage", "not a pid", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := handedOffPID(tt.output, 4242)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("%s: handedOffPID() = %d, %v, want %d, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// 健康检查探针类型
+const (
+	ProbeHTTP = "http"
+	ProbeTCP  = "tcp"
+	ProbeGRPC = "grpc"
+)
+
+// 探测历史最多保留的条数，避免活动结果过大
+const maxProbeHistory = 50
+
+// HealthCheckSpec 健康检查配置，零值字段使用默认值
+type HealthCheckSpec struct {
+	Type string
+	// HTTP 探针地址，为空时使用 Config.HealthCheckURL
+	URL string
+	// TCP/gRPC 探针地址 host:port
+	Address     string
+	GRPCService string
+
+	// HTTP 期望的状态码，为空时接受 2xx
+	ExpectedStatusCodes []int
+	BodyContains        string
+	// JSONPath 为点分路径（如 status 或 checks.0.state），响应中该字段需等于 JSONValue
+	JSONPath  string
+	JSONValue string
+
+	Interval         time.Duration
+	Timeout          time.Duration
+	SuccessThreshold int
+	Deadline         time.Duration
+}
+
+// ProbeResult 单次探测结果
+type ProbeResult struct {
+	Attempt    int
+	Time       time.Time
+	Duration   time.Duration
+	Success    bool
+	StatusCode int
+	Error      string
+}
+
+// HealthCheckResult 健康检查结果以及探测历史
+type HealthCheckResult struct {
+	Healthy              bool
+	Attempts             int
+	ConsecutiveSuccesses int
+	Probes               []ProbeResult
+}
+
+func (s HealthCheckSpec) withDefaults(config Config) HealthCheckSpec {
+	if s.Type == "" {
+		s.Type = ProbeHTTP
+	}
+	if s.URL == "" {
+		s.URL = config.HealthCheckURL
+	}
+	if s.Interval <= 0 {
+		s.Interval = 2 * time.Second
+	}
+	if s.Timeout <= 0 {
+		s.Timeout = 5 * time.Second
+	}
+	if s.SuccessThreshold <= 0 {
+		s.SuccessThreshold = 3
+	}
+	if s.Deadline <= 0 {
+		s.Deadline = 2 * time.Minute
+	}
+	return s
+}
+
+// target 返回探测目标，用于日志展示
+func (s HealthCheckSpec) target() string {
+	if s.Type == ProbeHTTP {
+		return s.URL
+	}
+	return s.Type + "://" + s.Address
+}
+
+// verifyHealth 持续探测直到连续成功次数达到阈值或超过截止时间
+func verifyHealth(ctx context.Context, spec HealthCheckSpec, logs *activityLog) (HealthCheckResult, error) {
+	var result HealthCheckResult
+	deadline := time.Now().Add(spec.Deadline)
+
+	for {
+		result.Attempts++
+		probe := runProbe(ctx, spec)
+		probe.Attempt = result.Attempts
+		result.Probes = append(result.Probes, probe)
+		if len(result.Probes) > maxProbeHistory {
+			result.Probes = result.Probes[1:]
+		}
+
+		if probe.Success {
+			result.ConsecutiveSuccesses++
+			logs.Printf("probe %d succeeded in %s (%d/%d)", probe.Attempt, probe.Duration, result.ConsecutiveSuccesses, spec.SuccessThreshold)
+		} else {
+			result.ConsecutiveSuccesses = 0
+			logs.Printf("probe %d failed in %s: %s", probe.Attempt, probe.Duration, probe.Error)
+		}
+		reportProgress(ctx, "probe %d: %d/%d consecutive successes", probe.Attempt, result.ConsecutiveSuccesses, spec.SuccessThreshold)
+
+		if result.ConsecutiveSuccesses >= spec.SuccessThreshold {
+			result.Healthy = true
+			return result, nil
+		}
+		if time.Now().Add(spec.Interval).After(deadline) {
+			return result, fmt.Errorf("not healthy after %d probes within %s: %s", result.Attempts, spec.Deadline, probe.Error)
+		}
+
+		timer := time.NewTimer(spec.Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func runProbe(ctx context.Context, spec HealthCheckSpec) ProbeResult {
+	ctx, cancel := context.WithTimeout(ctx, spec.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	var probe ProbeResult
+	var err error
+	switch spec.Type {
+	case ProbeHTTP:
+		probe.StatusCode, err = probeHTTP(ctx, spec)
+	case ProbeTCP:
+		err = probeTCP(ctx, spec)
+	case ProbeGRPC:
+		err = probeGRPC(ctx, spec)
+	default:
+		err = fmt.Errorf("unknown probe type %q", spec.Type)
+	}
+	probe.Time = start
+	probe.Duration = time.Since(start)
+	probe.Success = err == nil
+	if err != nil {
+		probe.Error = err.Error()
+	}
+	return probe
+}
+
+func probeHTTP(ctx context.Context, spec HealthCheckSpec) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, spec.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if !statusExpected(resp.StatusCode, spec.ExpectedStatusCodes) {
+		return resp.StatusCode, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	if spec.BodyContains == "" && spec.JSONPath == "" {
+		return resp.StatusCode, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if spec.BodyContains != "" && !strings.Contains(string(body), spec.BodyContains) {
+		return resp.StatusCode, fmt.Errorf("body does not contain %q", spec.BodyContains)
+	}
+	if spec.JSONPath != "" {
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return resp.StatusCode, fmt.Errorf("invalid json body: %v", err)
+		}
+		value, ok := lookupJSONPath(doc, spec.JSONPath)
+		if !ok {
+			return resp.StatusCode, fmt.Errorf("json path %s not found", spec.JSONPath)
+		}
+		if got := fmt.Sprint(value); got != spec.JSONValue {
+			return resp.StatusCode, fmt.Errorf("json path %s = %q, want %q", spec.JSONPath, got, spec.JSONValue)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+func statusExpected(code int, expected []int) bool {
+	if len(expected) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, c := range expected {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupJSONPath 按点分路径读取 JSON 字段，数字段表示数组下标
+func lookupJSONPath(doc interface{}, path string) (interface{}, bool) {
+	current := doc
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func probeTCP(ctx context.Context, spec HealthCheckSpec) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", spec.Address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// probeGRPC 使用标准 gRPC 健康检查协议
+func probeGRPC(ctx context.Context, spec HealthCheckSpec) error {
+	conn, err := grpc.NewClient(spec.Address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: spec.GRPCService})
+	if err != nil {
+		return err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("grpc health status %s", resp.GetStatus())
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package pkg
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestVerifyHealth(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 前两次请求返回 503，模拟应用启动中
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":"UP","checks":[{"name":"db","state":"ok"}]}`))
+	}))
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	base := HealthCheckSpec{
+		URL:              server.URL,
+		Interval:         time.Millisecond,
+		Timeout:          time.Second,
+		SuccessThreshold: 2,
+		Deadline:         time.Second,
+	}
+	tests := []struct {
+		name     string
+		spec     func(HealthCheckSpec) HealthCheckSpec
+		healthy  bool
+		attempts int
+	}{
+		{
+			name:     "http json path",
+			spec:     func(s HealthCheckSpec) HealthCheckSpec { s.JSONPath, s.JSONValue = "checks.0.state", "ok"; return s },
+			healthy:  true,
+			attempts: 4,
+		},
+		{
+			name: "http body mismatch",
+			spec: func(s HealthCheckSpec) HealthCheckSpec {
+				s.BodyContains = "DOWN"
+				s.Deadline = 50 * time.Millisecond
+				return s
+			},
+			healthy: false,
+		},
+		{
+			name: "http expected status",
+			spec: func(s HealthCheckSpec) HealthCheckSpec {
+				s.ExpectedStatusCodes = []int{http.StatusServiceUnavailable}
+				return s
+			},
+			healthy:  true,
+			attempts: 2,
+		},
+		{
+			name: "tcp",
+			spec: func(s HealthCheckSpec) HealthCheckSpec {
+				s.Type, s.Address = ProbeTCP, listener.Addr().String()
+				return s
+			},
+			healthy:  true,
+			attempts: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&requests, 0)
+			spec := tt.spec(base).withDefaults(Config{})
+			result, err := verifyHealth(context.Background(), spec, &activityLog{out: &testWriter{t}})
+			if (err == nil) != tt.healthy || result.Healthy != tt.healthy {
+				t.Fatalf("verifyHealth() = %+v, %v, want healthy %v", result, err, tt.healthy)
+			}
+			if tt.attempts > 0 && result.Attempts != tt.attempts {
+				t.Errorf("verifyHealth() attempts = %d, want %d", result.Attempts, tt.attempts)
+			}
+		})
+	}
+}
+
+type testWriter struct{ t *testing.T }
+
+func (w *testWriter) Write(p []byte) (int, error) {
+	w.t.Log(string(p))
+	return len(p), nil
+}
@@ -0,0 +1,119 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/workflow"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"temporal-aone/backend/shared"
+)
+
+// HostDeployment 一次发布在单台主机上的结果，每台主机最近一条成功记录即为当前运行的版本
+type HostDeployment struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	ReleaseID   uint   `gorm:"uniqueIndex:idx_host_release" json:"release_id"`
+	Host        string `gorm:"size:255;uniqueIndex:idx_host_release;index:idx_host_app_env" json:"host"`
+	App         string `gorm:"size:128;index:idx_host_app_env" json:"app"`
+	Environment string `gorm:"size:64;index:idx_host_app_env" json:"environment"`
+	Version     string `gorm:"size:128" json:"version"`
+	Commit      string `gorm:"size:64" json:"commit"`
+	Status      string `gorm:"size:32" json:"status"`
+	Error       string `gorm:"type:text" json:"error,omitempty"`
+
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// InventoryFilter 查询主机清单的条件，零值字段不过滤
+type InventoryFilter struct {
+	App         string
+	Environment string
+	Host        string
+}
+
+func (f InventoryFilter) apply(db *gorm.DB) *gorm.DB {
+	if f.App != "" {
+		db = db.Where("app = ?", f.App)
+	}
+	if f.Environment != "" {
+		db = db.Where("environment = ?", f.Environment)
+	}
+	if f.Host != "" {
+		db = db.Where("host = ?", f.Host)
+	}
+	return db
+}
+
+// CurrentDeployments 返回每个应用在每台主机上当前运行的版本
+func CurrentDeployments(db *gorm.DB, filter InventoryFilter) ([]HostDeployment, error) {
+	latest := filter.apply(db.Model(&HostDeployment{})).
+		Select("MAX(id)").
+		Where("status = ?", ReleaseSucceeded).
+		Group("app, environment, host")
+	var deployments []HostDeployment
+	err := db.Where("id IN (?)", latest).Order("app, environment, host").Find(&deployments).Error
+	return deployments, err
+}
+
+// DeploymentHistory 返回主机上的发布历史，最新的在前
+func DeploymentHistory(db *gorm.DB, filter InventoryFilter, limit int) ([]HostDeployment, error) {
+	var deployments []HostDeployment
+	err := filter.apply(db).Order("id desc").Limit(limit).Find(&deployments).Error
+	return deployments, err
+}
+
+// hostDeployments 生成一批主机的发布结果
+func hostDeployments(release Release, hosts []string, status string, err error, started, finished time.Time) []HostDeployment {
+	deployments := make([]HostDeployment, 0, len(hosts))
+	for _, host := range hosts {
+		d := HostDeployment{
+			ReleaseID:   release.ID,
+			Host:        host,
+			App:         release.App,
+			Environment: release.Environment,
+			Version:     release.Version,
+			Commit:      release.Commit,
+			Status:      status,
+			StartedAt:   started,
+			FinishedAt:  finished,
+		}
+		if err != nil {
+			d.Error = err.Error()
+		}
+		deployments = append(deployments, d)
+	}
+	return deployments
+}
+
+// RecordHostDeploymentsActivity 写入或更新主机上的发布结果，同一次发布每台主机只有一条记录
+func RecordHostDeploymentsActivity(ctx context.Context, deployments []HostDeployment) error {
+	if len(deployments) == 0 {
+		return nil
+	}
+	err := shared.GetDB().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "release_id"}, {Name: "host"}},
+		DoUpdates: clause.AssignmentColumns([]string{"status", "error", "finished_at", "updated_at"}),
+	}).Create(&deployments).Error
+	if err != nil {
+		return fmt.Errorf("record host deployments error: %v", err)
+	}
+	return nil
+}
+
+// recordHosts 记录主机上的发布结果；记录失败只写日志，不影响发布
+func recordHosts(ctx workflow.Context, config Config, release Release, hosts []string, status string, err error, started time.Time) {
+	if release.ID == 0 {
+		return
+	}
+	deployments := hostDeployments(release, hosts, status, err, started, workflow.Now(ctx))
+	recordErr := workflow.ExecuteActivity(withStep(ctx, config, StepRecordRelease), RecordHostDeploymentsActivity, deployments).Get(ctx, nil)
+	if recordErr != nil {
+		workflow.GetLogger(ctx).Error("RecordHostDeploymentsActivity failed.", "Hosts", hosts, "Error", recordErr)
+	}
+}
@@ -0,0 +1,41 @@
+package pkg
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHostDeployments(t *testing.T) {
+	release := Release{ID: 7, App: "demo", Environment: "prod", Version: "v1.2.0", Commit: "abc123"}
+	started := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	finished := started.Add(time.Minute)
+
+	tests := []struct {
+		name   string
+		status string
+		err    error
+		want   string
+	}{
+		{"succeeded", ReleaseSucceeded, nil, ""},
+		{"failed", ReleaseFailed, errors.New("health check failed"), "health check failed"},
+	}
+
+	for _, tt := range tests {
+		got := hostDeployments(release, []string{"10.0.0.1:22", "10.0.0.2:22"}, tt.status, tt.err, started, finished)
+		if len(got) != 2 {
+			t.Fatalf("%s: got %d deployments, want 2", tt.name, len(got))
+		}
+		for i, d := range got {
+			if d.ReleaseID != 7 || d.App != "demo" || d.Environment != "prod" || d.Version != "v1.2.0" || d.Commit != "abc123" {
+				t.Errorf("%s: deployment %d = %+v does not match release", tt.name, i, d)
+			}
+			if d.Status != tt.status || d.Error != tt.want || !d.StartedAt.Equal(started) || !d.FinishedAt.Equal(finished) {
+				t.Errorf("%s: deployment %d = %+v", tt.name, i, d)
+			}
+		}
+		if got[1].Host != "10.0.0.2:22" {
+			t.Errorf("%s: host = %q", tt.name, got[1].Host)
+		}
+	}
+}
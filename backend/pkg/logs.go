@@ -0,0 +1,209 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+)
+
+const truncatedMarker = "\n... log truncated: size limit reached ...\n"
+
+// ActivityLogStore 按 工作流ID/运行ID/活动 保存活动输出
+type ActivityLogStore struct {
+	Dir      string
+	MaxBytes int64
+}
+
+// ActivityLogs 默认的活动日志存储，API 和 Worker 需要指向同一个目录
+var ActivityLogs = &ActivityLogStore{
+	Dir:      "logs/activities",
+	MaxBytes: 10 << 20,
+}
+
+// ActivityLogInfo 单个活动日志文件的信息
+type ActivityLogInfo struct {
+	Activity  string
+	Size      int64
+	UpdatedAt time.Time
+}
+
+// sanitizePathElement 防止工作流ID等参数逃逸出日志目录
+func sanitizePathElement(s string) string {
+	s = strings.ReplaceAll(s, "/", "_")
+	s = strings.ReplaceAll(s, "\\", "_")
+	if s == "" || s == "." || s == ".." {
+		return "_"
+	}
+	return s
+}
+
+func (s *ActivityLogStore) runDir(workflowID, runID string) string {
+	return filepath.Join(s.Dir, sanitizePathElement(workflowID), sanitizePathElement(runID))
+}
+
+func (s *ActivityLogStore) path(workflowID, runID, activityName string) string {
+	return filepath.Join(s.runDir(workflowID, runID), sanitizePathElement(activityName)+".log")
+}
+
+// List 列出一次运行中所有活动的日志
+func (s *ActivityLogStore) List(workflowID, runID string) ([]ActivityLogInfo, error) {
+	entries, err := os.ReadDir(s.runDir(workflowID, runID))
+	if err != nil {
+		return nil, err
+	}
+	var logs []ActivityLogInfo
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".log") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, ActivityLogInfo{
+			Activity:  strings.TrimSuffix(entry.Name(), ".log"),
+			Size:      info.Size(),
+			UpdatedAt: info.ModTime(),
+		})
+	}
+	sort.Slice(logs, func(i, j int) bool { return logs[i].UpdatedAt.Before(logs[j].UpdatedAt) })
+	return logs, nil
+}
+
+// Read 从 offset 开始读取日志，返回内容和下一次读取的 offset，用于增量拉取
+func (s *ActivityLogStore) Read(workflowID, runID, activityName string, offset int64) ([]byte, int64, error) {
+	file, err := os.Open(s.path(workflowID, runID, activityName))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, offset + int64(len(data)), nil
+}
+
+// Tail 读取日志最后 n 行
+func (s *ActivityLogStore) Tail(workflowID, runID, activityName string, n int) ([]string, error) {
+	file, err := os.Open(s.path(workflowID, runID, activityName))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return tailLines(file, n)
+}
+
+func tailLines(r io.Reader, n int) ([]string, error) {
+	lines := make([]string, 0, n)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(lines) == n {
+			lines = lines[1:]
+		}
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// activityLog 活动输出的写入器，超过大小限制后丢弃后续输出
+type activityLog struct {
+	mu        sync.Mutex
+	out       io.Writer
+	file      *os.File
+	written   int64
+	limit     int64
+	truncated bool
+}
+
+// openActivityLog 打开当前活动的日志文件，非活动上下文或打开失败时退回到标准输出
+func openActivityLog(ctx context.Context) *activityLog {
+	if ctx == nil || !activity.IsActivity(ctx) {
+		return &activityLog{out: os.Stdout}
+	}
+	info := activity.GetInfo(ctx)
+	l, err := ActivityLogs.open(info.WorkflowExecution.ID, info.WorkflowExecution.RunID, info.ActivityType.Name)
+	if err != nil {
+		fmt.Printf("open activity log error: %v\n", err)
+		return &activityLog{out: os.Stdout}
+	}
+	l.Printf("=== attempt %d started at %s ===", info.Attempt, time.Now().Format(time.RFC3339))
+	return l
+}
+
+func (s *ActivityLogStore) open(workflowID, runID, activityName string) (*activityLog, error) {
+	path := s.path(workflowID, runID, activityName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &activityLog{
+		out:       file,
+		file:      file,
+		written:   stat.Size(),
+		limit:     s.MaxBytes,
+		truncated: s.MaxBytes > 0 && stat.Size() >= s.MaxBytes,
+	}, nil
+}
+
+// Write 始终返回 len(p)，避免日志截断导致被执行的命令失败
+func (l *activityLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.truncated {
+		return len(p), nil
+	}
+	data := p
+	if l.limit > 0 && l.written+int64(len(data)) > l.limit {
+		data = data[:l.limit-l.written]
+		l.truncated = true
+	}
+	n, err := l.out.Write(data)
+	l.written += int64(n)
+	if err != nil {
+		return n, err
+	}
+	if l.truncated {
+		io.WriteString(l.out, truncatedMarker)
+	}
+	return len(p), nil
+}
+
+// Printf 写入一行日志
+func (l *activityLog) Printf(format string, args ...interface{}) {
+	line := fmt.Sprintf(format, args...)
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+	l.Write([]byte(line))
+}
+
+func (l *activityLog) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,70 @@
+package pkg
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestActivityLogStore(t *testing.T) {
+	store := &ActivityLogStore{Dir: t.TempDir(), MaxBytes: 16}
+
+	l, err := store.open("build-upload-workflow", "run-1", "BuildActivity")
+	if err != nil {
+		t.Fatalf("open() error = %v", err)
+	}
+	l.Printf("0123456789")
+	l.Printf("abcdefghij")
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	data, next, err := store.Read("build-upload-workflow", "run-1", "BuildActivity", 0)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	want := "0123456789\nabcde" + truncatedMarker
+	if string(data) != want {
+		t.Errorf("Read() = %q, want %q", data, want)
+	}
+	if next != int64(len(want)) {
+		t.Errorf("Read() next = %d, want %d", next, len(want))
+	}
+
+	logs, err := store.List("build-upload-workflow", "run-1")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(logs) != 1 || logs[0].Activity != "BuildActivity" {
+		t.Errorf("List() = %+v", logs)
+	}
+
+	if _, _, err := store.Read("../escape", "run-1", "BuildActivity", 0); !os.IsNotExist(err) {
+		t.Errorf("Read() with traversal error = %v, want not exist", err)
+	}
+}
+
+func TestTailLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		n     int
+		want  []string
+	}{
+		{name: "fewer lines than n", input: "a\nb\n", n: 5, want: []string{"a", "b"}},
+		{name: "last n lines", input: "a\nb\nc\nd\n", n: 2, want: []string{"c", "d"}},
+		{name: "empty", input: "", n: 3, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tailLines(strings.NewReader(tt.input), tt.n)
+			if err != nil {
+				t.Fatalf("tailLines() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tailLines() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package pkg
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EnvironmentVariable 按应用和环境保存的配置模板变量
+type EnvironmentVariable struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	App         string `gorm:"size:128;uniqueIndex:idx_app_env_key" json:"app"`
+	Environment string `gorm:"size:64;uniqueIndex:idx_app_env_key" json:"environment"`
+	Key         string `gorm:"size:128;uniqueIndex:idx_app_env_key" json:"key"`
+	Value       string `gorm:"type:text" json:"value"`
+	// 敏感变量在 API 中不返回明文
+	Secret    bool      `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RenderedConfig 每次构建按环境渲染出的配置文件
+type RenderedConfig struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	App          string `gorm:"size:128;index:idx_rendered_app_env" json:"app"`
+	Environment  string `gorm:"size:64;index:idx_rendered_app_env" json:"environment"`
+	Version      string `gorm:"size:128" json:"version"`
+	WorkflowID   string `gorm:"size:255" json:"workflow_id"`
+	RunID        string `gorm:"size:64" json:"run_id"`
+	TemplatePath string `gorm:"size:512" json:"template_path"`
+	OutputPath   string `gorm:"size:512" json:"output_path"`
+	// 敏感变量的值已脱敏，Checksum 为脱敏前实际渲染结果的校验和
+	Content  string `gorm:"type:longtext" json:"content"`
+	Checksum string `gorm:"size:64" json:"checksum"`
+	// 与同一应用同一环境上一次渲染结果的差异
+	Diff      string    `gorm:"type:longtext" json:"diff"`
+	Changed   bool      `json:"changed"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Migrate 创建或更新发布相关的表
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&EnvironmentVariable{},
+		&RenderedConfig{},
+		&Environment{},
+		&Release{},
+		&DeployWindow{},
+		&FreezePeriod{},
+		&PolicyOverride{},
+		&Application{},
+		&Subscription{},
+		&Delivery{},
+		&Changelog{},
+		&HostDeployment{},
+		&Artifact{},
+	)
+}
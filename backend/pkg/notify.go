@@ -0,0 +1,400 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"temporal-aone/backend/shared"
+)
+
+// 工作流事件类型前缀，与状态组成事件名，例如 build.succeeded
+const (
+	EventBuild         = "build"
+	EventRelease       = "release"
+	EventConfigRelease = "config_release"
+	EventPromote       = "promote"
+)
+
+// 通知渠道
+const (
+	ChannelCommitStatus = "commit_status"
+	ChannelWebhook      = "webhook"
+	ChannelChat         = "chat"
+	ChannelEmail        = "email"
+)
+
+// ErrTypeNotification 模板错误或被对方拒绝的通知，重试没有意义
+const ErrTypeNotification = "NotificationError"
+
+// 默认的聊天和邮件模板
+const (
+	defaultChatTemplate = `{"text": {{ printf "%s %s %s%s%s" .Type .App .Version (env .Environment) (reason .Error) | json }}}`
+	defaultSubject      = `[{{ .App }}] {{ .Type }} {{ .Version }}`
+	defaultEmailBody    = `{{ .Type }} {{ .App }} {{ .Version }}{{ env .Environment }}
+workflow: {{ .WorkflowID }} ({{ .RunID }})
+commit: {{ .Commit }}
+started: {{ .StartedAt }}
+finished: {{ .FinishedAt }}
+{{ if .Error }}error: {{ .Error }}
+{{ end }}{{ if .Changelog }}
+{{ .Changelog }}{{ end }}`
+)
+
+// Event 工作流完成或失败时发出的事件，通知和订阅都使用它作为负载
+type Event struct {
+	Type        string    `json:"type"`
+	App         string    `json:"app"`
+	Environment string    `json:"environment,omitempty"`
+	Version     string    `json:"version"`
+	Ref         string    `json:"ref,omitempty"`
+	Commit      string    `json:"commit,omitempty"`
+	Hosts       []string  `json:"hosts,omitempty"`
+	WorkflowID  string    `json:"workflow_id"`
+	RunID       string    `json:"run_id"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	Changelog   string    `json:"changelog,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+}
+
+// NotifySpec 工作流结束时的通知配置
+type NotifySpec struct {
+	// 向 Git 平台回写提交状态，需要 Config.Commit 和 Config.Token
+	CommitStatus bool
+	// github、gitlab 或 gitea
+	GitProvider string
+	// Git 平台 API 地址，为空时按仓库地址推断
+	GitAPIURL string
+	// 提交状态中的链接，例如发布页面地址
+	TargetURL string
+
+	Webhooks []string
+	Chats    []ChatTarget
+	Email    *EmailTarget
+}
+
+// ChatTarget 聊天机器人 webhook，Template 渲染出请求体 JSON
+type ChatTarget struct {
+	URL      string
+	Template string
+}
+
+// EmailTarget 邮件收件人和模板，SMTP 服务器在服务端配置
+type EmailTarget struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// NotifyChannel 单个通知目标，每个目标由一个活动投递并独立重试
+type NotifyChannel struct {
+	Type     string
+	URL      string
+	Template string
+	To       []string
+	Subject  string
+
+	// 提交状态使用
+	Provider  string
+	RepoURL   string
+	Token     string
+	TargetURL string
+}
+
+// channels 展开为通知目标列表
+func (s NotifySpec) channels(config Config) []NotifyChannel {
+	var channels []NotifyChannel
+	if s.CommitStatus && config.Commit != "" {
+		channels = append(channels, NotifyChannel{
+			Type:      ChannelCommitStatus,
+			URL:       s.GitAPIURL,
+			Provider:  s.GitProvider,
+			RepoURL:   config.RepoURL,
+			Token:     config.Token,
+			TargetURL: s.TargetURL,
+		})
+	}
+	for _, u := range s.Webhooks {
+		channels = append(channels, NotifyChannel{Type: ChannelWebhook, URL: u})
+	}
+	for _, chat := range s.Chats {
+		channels = append(channels, NotifyChannel{Type: ChannelChat, URL: chat.URL, Template: chat.Template})
+	}
+	if s.Email != nil && len(s.Email.To) > 0 {
+		channels = append(channels, NotifyChannel{Type: ChannelEmail, To: s.Email.To, Subject: s.Email.Subject, Template: s.Email.Body})
+	}
+	return channels
+}
+
+// newEvent 根据工作流结果生成事件
+func newEvent(ctx workflow.Context, kind string, config Config, started time.Time, err error) Event {
+	info := workflow.GetInfo(ctx)
+	event := Event{
+		App:         config.AppName(),
+		Environment: config.Environment,
+		Version:     config.Version,
+		Ref:         config.Tag,
+		Commit:      config.Commit,
+		Hosts:       config.releaseHosts(),
+		WorkflowID:  info.WorkflowExecution.ID,
+		RunID:       info.WorkflowExecution.RunID,
+		Status:      ReleaseSucceeded,
+		Changelog:   config.Changelog,
+		StartedAt:   started,
+		FinishedAt:  workflow.Now(ctx),
+	}
+	if err != nil {
+		event.Status = ReleaseFailed
+		event.Error = err.Error()
+	}
+	event.Type = kind + "." + event.Status
+	return event
+}
+
+// notifyOutcome 在工作流返回时发布事件并发送通知，失败只记录日志，不影响工作流结果
+func notifyOutcome(ctx workflow.Context, config *Config, kind string, started time.Time, err *error) {
+	event := newEvent(ctx, kind, *config, started, *err)
+
+	// 工作流被取消时仍然发送
+	ctx, _ = workflow.NewDisconnectedContext(ctx)
+	publishEvent(ctx, *config, event)
+
+	channels := config.Notify.channels(*config)
+	if len(channels) == 0 {
+		return
+	}
+	ctx = withStep(ctx, *config, StepNotify)
+	futures := make([]workflow.Future, len(channels))
+	for i, channel := range channels {
+		futures[i] = workflow.ExecuteActivity(ctx, NotifyActivity, channel, event)
+	}
+	for i, future := range futures {
+		if notifyErr := future.Get(ctx, nil); notifyErr != nil {
+			workflow.GetLogger(ctx).Warn("NotifyActivity failed.", "Channel", channels[i].Type, "Error", notifyErr)
+		}
+	}
+}
+
+// renderNotification 用事件渲染通知模板
+func renderNotification(name, text string, event Event) (string, error) {
+	tmpl, err := template.New(name).Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		"env": func(environment string) string {
+			if environment == "" {
+				return ""
+			}
+			return " to " + environment
+		},
+		"reason": func(err string) string {
+			if err == "" {
+				return ""
+			}
+			return ": " + err
+		},
+	}).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, event); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// NotifyActivity 向一个通知目标投递事件
+func NotifyActivity(ctx context.Context, channel NotifyChannel, event Event) error {
+	switch channel.Type {
+	case ChannelCommitStatus:
+		req, err := commitStatusRequest(ctx, channel, event)
+		if err != nil {
+			return temporal.NewNonRetryableApplicationError(err.Error(), ErrTypeNotification, nil)
+		}
+		return sendNotification(req)
+	case ChannelWebhook:
+		body, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		return postJSON(ctx, channel.URL, body, nil)
+	case ChannelChat:
+		text := channel.Template
+		if text == "" {
+			text = defaultChatTemplate
+		}
+		body, err := renderNotification("chat", text, event)
+		if err != nil {
+			return temporal.NewNonRetryableApplicationError(fmt.Sprintf("render chat template error: %v", err), ErrTypeNotification, nil)
+		}
+		return postJSON(ctx, channel.URL, []byte(body), nil)
+	case ChannelEmail:
+		return sendEmail(channel, event)
+	}
+	return temporal.NewNonRetryableApplicationError(fmt.Sprintf("unknown notification channel %q", channel.Type), ErrTypeNotification, nil)
+}
+
+func postJSON(ctx context.Context, target string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return temporal.NewNonRetryableApplicationError(err.Error(), ErrTypeNotification, nil)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	return sendNotification(req)
+}
+
+// sendNotification 发送请求，4xx（429 除外）视为不可重试
+func sendNotification(req *http.Request) error {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("notify %s error: %v", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("notify %s: status %d: %s", req.URL.Host, resp.StatusCode, strings.TrimSpace(string(body)))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return temporal.NewNonRetryableApplicationError(err.Error(), ErrTypeNotification, nil)
+	}
+	return err
+}
+
+// commitStatusRequest 生成 GitHub、GitLab 或 Gitea 的提交状态请求
+func commitStatusRequest(ctx context.Context, channel NotifyChannel, event Event) (*http.Request, error) {
+	key := NormalizeRepoURL(channel.RepoURL)
+	host, repo, ok := strings.Cut(key, "/")
+	if !ok {
+		return nil, fmt.Errorf("cannot derive repository from %q", channel.RepoURL)
+	}
+	name := "temporal-aone/" + strings.SplitN(event.Type, ".", 2)[0]
+	description := fmt.Sprintf("%s %s", event.Version, event.Status)
+	if event.Error != "" {
+		description = event.Error
+	}
+	// 各平台对描述长度有限制
+	if len(description) > 140 {
+		description = description[:140]
+	}
+
+	apiURL := strings.TrimSuffix(channel.URL, "/")
+	var req *http.Request
+	var err error
+	switch channel.Provider {
+	case ProviderGitHub, ProviderGitea:
+		state := "success"
+		if event.Status != ReleaseSucceeded {
+			state = "failure"
+		}
+		if apiURL == "" {
+			apiURL = "https://" + host + "/api/v1"
+			if channel.Provider == ProviderGitHub {
+				apiURL = "https://api.github.com"
+			}
+		}
+		body, _ := json.Marshal(map[string]string{
+			"state":       state,
+			"context":     name,
+			"description": description,
+			"target_url":  channel.TargetURL,
+		})
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost,
+			fmt.Sprintf("%s/repos/%s/statuses/%s", apiURL, repo, event.Commit), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if channel.Provider == ProviderGitHub {
+			req.Header.Set("Authorization", "Bearer "+channel.Token)
+			req.Header.Set("Accept", "application/vnd.github+json")
+		} else {
+			req.Header.Set("Authorization", "token "+channel.Token)
+		}
+	case ProviderGitLab:
+		state := "success"
+		if event.Status != ReleaseSucceeded {
+			state = "failed"
+		}
+		if apiURL == "" {
+			apiURL = "https://" + host + "/api/v4"
+		}
+		query := url.Values{
+			"state":       {state},
+			"name":        {name},
+			"description": {description},
+		}
+		if channel.TargetURL != "" {
+			query.Set("target_url", channel.TargetURL)
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost,
+			fmt.Sprintf("%s/projects/%s/statuses/%s?%s", apiURL, url.PathEscape(repo), event.Commit, query.Encode()), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("PRIVATE-TOKEN", channel.Token)
+	default:
+		return nil, fmt.Errorf("unknown git provider %q", channel.Provider)
+	}
+	return req, nil
+}
+
+// sendEmail 通过服务端配置的 SMTP 服务器发送邮件
+func sendEmail(channel NotifyChannel, event Event) error {
+	cfg := shared.Config.Notify.SMTP
+	if cfg.Host == "" {
+		return temporal.NewNonRetryableApplicationError("smtp server not configured", ErrTypeNotification, nil)
+	}
+	subjectTemplate, bodyTemplate := channel.Subject, channel.Template
+	if subjectTemplate == "" {
+		subjectTemplate = defaultSubject
+	}
+	if bodyTemplate == "" {
+		bodyTemplate = defaultEmailBody
+	}
+	subject, err := renderNotification("subject", subjectTemplate, event)
+	if err != nil {
+		return temporal.NewNonRetryableApplicationError(fmt.Sprintf("render subject error: %v", err), ErrTypeNotification, nil)
+	}
+	body, err := renderNotification("body", bodyTemplate, event)
+	if err != nil {
+		return temporal.NewNonRetryableApplicationError(fmt.Sprintf("render body error: %v", err), ErrTypeNotification, nil)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(channel.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", strings.ReplaceAll(subject, "\n", " "))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(body)
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	addr := cfg.Host + ":" + strconv.Itoa(cfg.Port)
+	if err := smtp.SendMail(addr, auth, cfg.From, channel.To, msg.Bytes()); err != nil {
+		return fmt.Errorf("send email error: %v", err)
+	}
+	return nil
+}
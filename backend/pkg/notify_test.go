@@ -0,0 +1,108 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDefaultChatTemplate(t *testing.T) {
+	event := Event{Type: "release.failed", App: "demo", Version: "v1.2.0", Environment: "prod", Error: `health check "http" failed`}
+	body, err := renderNotification("chat", defaultChatTemplate, event)
+	if err != nil {
+		t.Fatalf("renderNotification() error = %v", err)
+	}
+	var payload map[string]string
+	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+		t.Fatalf("chat body is not valid JSON: %v\n%s", err, body)
+	}
+	want := `release.failed demo v1.2.0 to prod: health check "http" failed`
+	if payload["text"] != want {
+		t.Errorf("text = %q, want %q", payload["text"], want)
+	}
+}
+
+func TestCommitStatusRequest(t *testing.T) {
+	event := Event{Type: "build.succeeded", Version: "abc", Status: ReleaseSucceeded, Commit: "0123abcd"}
+	tests := []struct {
+		name    string
+		channel NotifyChannel
+		url     string
+		header  string
+		value   string
+	}{
+		{
+			"github",
+			NotifyChannel{Provider: ProviderGitHub, RepoURL: "https://github.com/DPSDL/temporal-aone.git", Token: "t"},
+			"https://api.github.com/repos/dpsdl/temporal-aone/statuses/0123abcd", "Authorization", "Bearer t",
+		},
+		{
+			"gitea",
+			NotifyChannel{Provider: ProviderGitea, RepoURL: "git@gitea.local:team/app.git", Token: "t"},
+			"https://gitea.local/api/v1/repos/team/app/statuses/0123abcd", "Authorization", "token t",
+		},
+		{
+			"gitlab",
+			NotifyChannel{Provider: ProviderGitLab, RepoURL: "https://gitlab.example.com/group/app", Token: "t", URL: "https://gitlab.example.com/api/v4/"},
+			"https://gitlab.example.com/api/v4/projects/group%2Fapp/statuses/0123abcd?description=abc+succeeded&name=temporal-aone%2Fbuild&state=success",
+			"PRIVATE-TOKEN", "t",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := commitStatusRequest(context.Background(), tt.channel, event)
+			if err != nil {
+				t.Fatalf("commitStatusRequest() error = %v", err)
+			}
+			if req.URL.String() != tt.url {
+				t.Errorf("url = %s, want %s", req.URL, tt.url)
+			}
+			if got := req.Header.Get(tt.header); got != tt.value {
+				t.Errorf("%s = %q, want %q", tt.header, got, tt.value)
+			}
+		})
+	}
+}
+
+func TestNotifyActivityWebhook(t *testing.T) {
+	var received Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		if r.URL.Path == "/reject" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	event := Event{Type: "build.succeeded", App: "demo"}
+	if err := NotifyActivity(context.Background(), NotifyChannel{Type: ChannelWebhook, URL: server.URL}, event); err != nil {
+		t.Fatalf("NotifyActivity() error = %v", err)
+	}
+	if received.Type != "build.succeeded" || received.App != "demo" {
+		t.Errorf("received %+v", received)
+	}
+	if err := NotifyActivity(context.Background(), NotifyChannel{Type: ChannelWebhook, URL: server.URL + "/reject"}, event); err == nil {
+		t.Error("expected error for rejected webhook")
+	}
+}
+
+func TestNotifyChannels(t *testing.T) {
+	spec := NotifySpec{
+		CommitStatus: true,
+		Webhooks:     []string{"http://a", "http://b"},
+		Email:        &EmailTarget{To: []string{"ops@example.com"}},
+	}
+	if got := len(spec.channels(Config{})); got != 3 {
+		t.Errorf("channels without commit = %d, want 3", got)
+	}
+	if got := len(spec.channels(Config{Commit: "abc"})); got != 4 {
+		t.Errorf("channels with commit = %d, want 4", got)
+	}
+}
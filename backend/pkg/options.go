@@ -0,0 +1,328 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// 工作流步骤名称，用于进度展示和按步骤配置活动选项
+const (
+	StepConfigRepo  = "config_repo"
+	StepBuild       = "build"
+	StepTest        = "test"
+	StepPackage     = "package"
+	StepUpload      = "upload"
+	StepShutdown    = "shutdown"
+	StepRestart     = "restart"
+	StepHealthCheck = "health_check"
+	StepCanary      = "canary_analysis"
+	StepRollback    = "rollback"
+	StepSwitch      = "switch_traffic"
+	StepDrain       = "drain"
+	StepRetire      = "retire"
+	StepInstall     = "install"
+	StepUnitStatus  = "unit_status"
+	StepActivate    = "activate"
+	StepPrune       = "prune"
+
+	StepFetchConfig      = "fetch_config"
+	StepValidateConfig   = "validate_config"
+	StepDiffConfig       = "diff_config"
+	StepDistributeConfig = "distribute_config"
+	StepReload           = "reload"
+
+	StepRenderConfig  = "render_config"
+	StepRecordRelease = "record_release"
+	StepPromote       = "promote"
+	StepPolicy        = "policy"
+	StepCheckout      = "checkout"
+	StepNotify        = "notify"
+	StepDispatchEvent = "dispatch_event"
+	StepChangelog     = "changelog"
+	StepVersion       = "version"
+	StepTag           = "tag"
+	StepListReleases  = "list_releases"
+	StepStageArtifact = "stage_artifact"
+)
+
+// 活动返回的错误类型，可在重试策略中配置为不可重试
+const (
+	ErrTypeBuild         = "BuildError"
+	ErrTypeTest          = "TestError"
+	ErrTypeHealthCheck   = "HealthCheckError"
+	ErrTypeRemoteCommand = "RemoteCommandError"
+	ErrTypeUnitInactive  = "UnitInactiveError"
+)
+
+// RetryOptions 活动重试策略，零值字段使用 Temporal 默认值
+type RetryOptions struct {
+	InitialInterval        time.Duration
+	BackoffCoefficient     float64
+	MaximumInterval        time.Duration
+	MaximumAttempts        int32
+	NonRetryableErrorTypes []string
+}
+
+// StepOptions 单个步骤的超时和重试配置
+type StepOptions struct {
+	StartToCloseTimeout time.Duration
+	HeartbeatTimeout    time.Duration
+	Retry               *RetryOptions
+}
+
+// defaultStartToCloseTimeout 未配置的步骤使用的超时时间
+const defaultStartToCloseTimeout = time.Minute
+
+// DefaultStepOptions 各步骤的默认配置，可被 Config.Steps 覆盖
+var DefaultStepOptions = map[string]StepOptions{
+	StepConfigRepo: {StartToCloseTimeout: 10 * time.Minute},
+	StepBuild: {
+		StartToCloseTimeout: 30 * time.Minute,
+		Retry:               &RetryOptions{MaximumAttempts: 3, NonRetryableErrorTypes: []string{ErrTypeBuild}},
+	},
+	StepTest: {
+		StartToCloseTimeout: 30 * time.Minute,
+		Retry:               &RetryOptions{MaximumAttempts: 3, NonRetryableErrorTypes: []string{ErrTypeTest}},
+	},
+	StepPackage: {StartToCloseTimeout: 10 * time.Minute},
+	StepUpload:  {StartToCloseTimeout: 30 * time.Minute},
+	// 停止和启动应用不是幂等操作，默认不重试
+	StepShutdown: {StartToCloseTimeout: 2 * time.Minute, Retry: &RetryOptions{MaximumAttempts: 1}},
+	StepRestart:  {StartToCloseTimeout: 2 * time.Minute, Retry: &RetryOptions{MaximumAttempts: 1}},
+	// 健康检查每次探测都会发送心跳
+	StepHealthCheck: {
+		StartToCloseTimeout: 5 * time.Minute,
+		HeartbeatTimeout:    time.Minute,
+		Retry:               &RetryOptions{MaximumAttempts: 1},
+	},
+	// 指标地址不可达时有限次重试后失败，不会无限期停留在金丝雀阶段
+	StepCanary:      {StartToCloseTimeout: time.Minute, Retry: &RetryOptions{MaximumAttempts: 5}},
+	StepRollback:    {StartToCloseTimeout: 5 * time.Minute, Retry: &RetryOptions{MaximumAttempts: 3}},
+	StepSwitch:      {StartToCloseTimeout: 2 * time.Minute, Retry: &RetryOptions{MaximumAttempts: 3}},
+	StepRetire:      {StartToCloseTimeout: 2 * time.Minute, Retry: &RetryOptions{MaximumAttempts: 3}},
+	StepInstall:     {StartToCloseTimeout: 5 * time.Minute, Retry: &RetryOptions{MaximumAttempts: 3}},
+	StepActivate:    {StartToCloseTimeout: time.Minute, Retry: &RetryOptions{MaximumAttempts: 3}},
+	StepPrune:       {StartToCloseTimeout: 2 * time.Minute, Retry: &RetryOptions{MaximumAttempts: 2}},
+	StepFetchConfig: {StartToCloseTimeout: 5 * time.Minute},
+	StepValidateConfig: {
+		StartToCloseTimeout: time.Minute,
+		Retry:               &RetryOptions{NonRetryableErrorTypes: []string{ErrTypeInvalidConfig}},
+	},
+	StepDiffConfig:       {StartToCloseTimeout: time.Minute},
+	StepDistributeConfig: {StartToCloseTimeout: 2 * time.Minute, Retry: &RetryOptions{MaximumAttempts: 3}},
+	StepReload:           {StartToCloseTimeout: time.Minute, Retry: &RetryOptions{MaximumAttempts: 1}},
+	StepUnitStatus: {
+		StartToCloseTimeout: time.Minute,
+		Retry:               &RetryOptions{MaximumAttempts: 3, NonRetryableErrorTypes: []string{ErrTypeUnitInactive}},
+	},
+	StepRenderConfig:  {StartToCloseTimeout: time.Minute, Retry: &RetryOptions{MaximumAttempts: 3}},
+	StepRecordRelease: {StartToCloseTimeout: 30 * time.Second},
+	StepPolicy:        {StartToCloseTimeout: 30 * time.Second},
+	StepCheckout:      {StartToCloseTimeout: 10 * time.Minute, Retry: &RetryOptions{MaximumAttempts: 3}},
+	StepNotify: {
+		StartToCloseTimeout: time.Minute,
+		Retry:               &RetryOptions{MaximumAttempts: 10, NonRetryableErrorTypes: []string{ErrTypeNotification}},
+	},
+	StepChangelog: {StartToCloseTimeout: 2 * time.Minute, Retry: &RetryOptions{MaximumAttempts: 3}},
+	StepVersion: {
+		StartToCloseTimeout: 2 * time.Minute,
+		Retry:               &RetryOptions{MaximumAttempts: 3, NonRetryableErrorTypes: []string{ErrTypeVersion}},
+	},
+	StepTag: {
+		StartToCloseTimeout: 5 * time.Minute,
+		Retry:               &RetryOptions{MaximumAttempts: 3, NonRetryableErrorTypes: []string{ErrTypeVersion}},
+	},
+	StepListReleases:  {StartToCloseTimeout: time.Minute, Retry: &RetryOptions{MaximumAttempts: 2}},
+	StepStageArtifact: {StartToCloseTimeout: 10 * time.Minute, Retry: &RetryOptions{MaximumAttempts: 3}},
+	StepDispatchEvent: {
+		StartToCloseTimeout: time.Minute,
+		Retry: &RetryOptions{
+			InitialInterval:        10 * time.Second,
+			BackoffCoefficient:     2,
+			MaximumInterval:        30 * time.Minute,
+			MaximumAttempts:        12,
+			NonRetryableErrorTypes: []string{ErrTypeNotification},
+		},
+	},
+}
+
+// merge 用 override 中的非零字段覆盖当前配置
+func (o StepOptions) merge(override StepOptions) StepOptions {
+	if override.StartToCloseTimeout > 0 {
+		o.StartToCloseTimeout = override.StartToCloseTimeout
+	}
+	if override.HeartbeatTimeout > 0 {
+		o.HeartbeatTimeout = override.HeartbeatTimeout
+	}
+	if override.Retry != nil {
+		o.Retry = override.Retry
+	}
+	return o
+}
+
+// stepOptions 返回步骤最终生效的配置
+func stepOptions(config Config, step string) StepOptions {
+	opts := StepOptions{StartToCloseTimeout: defaultStartToCloseTimeout}
+	opts = opts.merge(DefaultStepOptions[step])
+	return opts.merge(config.Steps[step])
+}
+
+func (o StepOptions) activityOptions() workflow.ActivityOptions {
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout: o.StartToCloseTimeout,
+		HeartbeatTimeout:    o.HeartbeatTimeout,
+	}
+	if o.Retry != nil {
+		ao.RetryPolicy = &temporal.RetryPolicy{
+			InitialInterval:        o.Retry.InitialInterval,
+			BackoffCoefficient:     o.Retry.BackoffCoefficient,
+			MaximumInterval:        o.Retry.MaximumInterval,
+			MaximumAttempts:        o.Retry.MaximumAttempts,
+			NonRetryableErrorTypes: o.Retry.NonRetryableErrorTypes,
+		}
+	}
+	return ao
+}
+
+// withStep 为指定步骤设置活动选项
+func withStep(ctx workflow.Context, config Config, step string) workflow.Context {
+	return workflow.WithActivityOptions(ctx, stepOptions(config, step).activityOptions())
+}
+
+// heartbeatInterval 返回活动心跳间隔，配置了心跳超时时取其一半
+func heartbeatInterval(ctx context.Context) time.Duration {
+	interval := 10 * time.Second
+	if ctx == nil || !activity.IsActivity(ctx) {
+		return interval
+	}
+	if timeout := activity.GetInfo(ctx).HeartbeatTimeout; timeout > 0 && timeout/2 < interval {
+		interval = timeout / 2
+	}
+	return interval
+}
+
+// ProcessKillGrace 活动被取消后等待命令自行退出的时间，超时后强制结束，Worker 启动时按配置设置
+var ProcessKillGrace = 10 * time.Second
+
+// runCommand 执行命令并在运行期间定期发送心跳，避免长时间构建触发心跳超时；
+// 活动被取消（例如 Worker 关闭）时先向命令的进程组发送 SIGTERM，超过 ProcessKillGrace 后发送 SIGKILL
+func runCommand(ctx context.Context, cmd *exec.Cmd) error {
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	runningCommands.add(cmd)
+	done := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		runningCommands.remove(cmd)
+		done <- err
+	}()
+
+	var canceled <-chan struct{}
+	if ctx != nil {
+		canceled = ctx.Done()
+	}
+	ticker := time.NewTicker(heartbeatInterval(ctx))
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-ticker.C:
+			reportProgress(ctx, "%s still running", cmd.Path)
+		case <-canceled:
+			return stopCommand(cmd, done, ctx.Err())
+		}
+	}
+}
+
+// stopCommand 先请求命令退出，宽限期后强制结束，返回取消原因
+func stopCommand(cmd *exec.Cmd, done <-chan error, cause error) error {
+	_ = signalProcessGroup(cmd, syscall.SIGTERM)
+	timer := time.NewTimer(ProcessKillGrace)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return fmt.Errorf("%s stopped: %v", cmd.Path, cause)
+	case <-timer.C:
+		_ = signalProcessGroup(cmd, syscall.SIGKILL)
+		<-done
+		return fmt.Errorf("%s killed after %s: %v", cmd.Path, ProcessKillGrace, cause)
+	}
+}
+
+// commandRegistry 记录正在执行的命令，Worker 退出前等待被取消的命令结束，强制退出时结束它们的进程组
+type commandRegistry struct {
+	mu   sync.Mutex
+	cmds map[*exec.Cmd]struct{}
+	// 每次有命令结束时关闭并替换，用于唤醒等待者
+	changed chan struct{}
+}
+
+var runningCommands = &commandRegistry{
+	cmds:    make(map[*exec.Cmd]struct{}),
+	changed: make(chan struct{}),
+}
+
+func (r *commandRegistry) add(cmd *exec.Cmd) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cmds[cmd] = struct{}{}
+}
+
+func (r *commandRegistry) remove(cmd *exec.Cmd) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.cmds, cmd)
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// wait 等待全部命令结束，超时返回 false
+func (r *commandRegistry) wait(timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		r.mu.Lock()
+		running, changed := len(r.cmds), r.changed
+		r.mu.Unlock()
+		if running == 0 {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return false
+		}
+	}
+}
+
+// kill 向全部命令的进程组发送 SIGKILL，返回命令数量
+func (r *commandRegistry) kill() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for cmd := range r.cmds {
+		_ = signalProcessGroup(cmd, syscall.SIGKILL)
+	}
+	return len(r.cmds)
+}
+
+// WaitForCommands 等待正在执行的命令结束，超时返回 false。Worker.Stop 超时后只取消活动就返回，
+// 需要在退出前等待被取消的命令完成 SIGTERM 到 SIGKILL 的过程，否则进程组会在 Worker 退出后继续运行
+func WaitForCommands(timeout time.Duration) bool {
+	return runningCommands.wait(timeout)
+}
+
+// KillCommands 强制结束全部正在执行的命令及其子进程，返回命令数量
+func KillCommands() int {
+	return runningCommands.kill()
+}
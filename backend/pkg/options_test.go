@@ -0,0 +1,123 @@
+package pkg
+
+import (
+	"context"
+	"os/exec"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStepOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		step   string
+		want   StepOptions
+	}{
+		{
+			name: "unknown step uses fallback timeout",
+			step: "unknown",
+			want: StepOptions{StartToCloseTimeout: defaultStartToCloseTimeout},
+		},
+		{
+			name: "built-in default",
+			step: StepRestart,
+			want: StepOptions{StartToCloseTimeout: 2 * time.Minute, Retry: &RetryOptions{MaximumAttempts: 1}},
+		},
+		{
+			name: "config overrides only non-zero fields",
+			config: Config{Steps: map[string]StepOptions{
+				StepBuild: {HeartbeatTimeout: time.Minute},
+			}},
+			step: StepBuild,
+			want: StepOptions{
+				StartToCloseTimeout: 30 * time.Minute,
+				HeartbeatTimeout:    time.Minute,
+				Retry:               &RetryOptions{MaximumAttempts: 3, NonRetryableErrorTypes: []string{ErrTypeBuild}},
+			},
+		},
+		{
+			name: "config replaces retry policy",
+			config: Config{Steps: map[string]StepOptions{
+				StepRestart: {Retry: &RetryOptions{MaximumAttempts: 2}},
+			}},
+			step: StepRestart,
+			want: StepOptions{StartToCloseTimeout: 2 * time.Minute, Retry: &RetryOptions{MaximumAttempts: 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stepOptions(tt.config, tt.step); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("stepOptions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunCommandCanceled(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process groups are not used on windows")
+	}
+	defer func(grace time.Duration) { ProcessKillGrace = grace }(ProcessKillGrace)
+	ProcessKillGrace = 500 * time.Millisecond
+
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{"exits on SIGTERM", "sleep 30", "stopped"},
+		{"ignores SIGTERM", `trap "" TERM; while :; do sleep 0.1; done`, "killed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(200*time.Millisecond, cancel)
+
+			started := time.Now()
+			err := runCommand(ctx, exec.Command("sh", "-c", tt.script))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("runCommand() error = %v, want %q", err, tt.want)
+			}
+			if !strings.Contains(err.Error(), context.Canceled.Error()) {
+				t.Errorf("runCommand() error = %v, want cancellation cause", err)
+			}
+			if elapsed := time.Since(started); elapsed > 5*time.Second {
+				t.Errorf("runCommand() took %s after cancel", elapsed)
+			}
+		})
+	}
+}
+
+func TestWaitAndKillCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process groups are not used on windows")
+	}
+	if !WaitForCommands(10 * time.Millisecond) {
+		t.Fatal("WaitForCommands() with no commands should return true")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		// 子进程在同一个进程组中，强制结束时一起结束
+		done <- runCommand(context.Background(), exec.Command("sh", "-c", "sleep 30 & wait"))
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	if WaitForCommands(100 * time.Millisecond) {
+		t.Fatal("WaitForCommands() should time out while a command is running")
+	}
+	if killed := KillCommands(); killed != 1 {
+		t.Errorf("KillCommands() = %d, want 1", killed)
+	}
+	if !WaitForCommands(5 * time.Second) {
+		t.Fatal("WaitForCommands() should return true after KillCommands")
+	}
+	if err := <-done; err == nil {
+		t.Error("runCommand() of a killed command should fail")
+	}
+}
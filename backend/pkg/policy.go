@@ -0,0 +1,266 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"temporal-aone/backend/shared"
+)
+
+// ErrTypePolicyViolation 发布时间不在允许的窗口内或处于封版期
+const ErrTypePolicyViolation = "PolicyViolationError"
+
+// 计算下一个可发布时间时最多跳过的窗口和封版期数量
+const maxPolicyIterations = 1000
+
+// DeployWindow 允许发布的时间窗口，Cron 为五段式表达式，匹配的每一分钟都允许发布，
+// 例如 "* 9-17 * * 1-5" 表示工作日 09:00-17:59
+type DeployWindow struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	App         string `gorm:"size:128;index:idx_window_app_env" json:"app"`
+	Environment string `gorm:"size:64;index:idx_window_app_env" json:"environment"`
+	Cron        string `gorm:"size:128" json:"cron"`
+	// IANA 时区，为空时使用 UTC
+	Timezone  string    `gorm:"size:64" json:"timezone"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FreezePeriod 封版期，[Start, End) 内禁止发布
+type FreezePeriod struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	App         string    `gorm:"size:128;index:idx_freeze_app_env" json:"app"`
+	Environment string    `gorm:"size:64;index:idx_freeze_app_env" json:"environment"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Reason      string    `gorm:"size:512" json:"reason"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// PolicyOverride 管理员绕过发布策略的审计记录
+type PolicyOverride struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	App         string    `gorm:"size:128;index:idx_override_app_env" json:"app"`
+	Environment string    `gorm:"size:64;index:idx_override_app_env" json:"environment"`
+	Version     string    `gorm:"size:128" json:"version"`
+	WorkflowID  string    `gorm:"size:255" json:"workflow_id"`
+	RunID       string    `gorm:"size:64" json:"run_id"`
+	Actor       string    `gorm:"size:128" json:"actor"`
+	Reason      string    `gorm:"size:512" json:"reason"`
+	Blocked     string    `gorm:"size:512" json:"blocked"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// OverrideSpec 管理员绕过发布策略时提供的信息
+type OverrideSpec struct {
+	Actor  string
+	Reason string
+}
+
+// DeploymentPolicy 环境的发布窗口和封版期，没有窗口时任何时间都允许发布
+type DeploymentPolicy struct {
+	Windows []DeployWindow
+	Freezes []FreezePeriod
+}
+
+// PolicyDecision 策略检查结果，NextAllowed 为零值表示五年内没有可发布时间
+type PolicyDecision struct {
+	Allowed     bool
+	Reason      string
+	NextAllowed time.Time
+}
+
+type windowSchedule struct {
+	schedule cron.Schedule
+	location *time.Location
+}
+
+// parseWindow 解析窗口的 Cron 表达式和时区
+func parseWindow(w DeployWindow) (windowSchedule, error) {
+	schedule, err := cron.ParseStandard(w.Cron)
+	if err != nil {
+		return windowSchedule{}, fmt.Errorf("invalid window %q: %v", w.Cron, err)
+	}
+	location := time.UTC
+	if w.Timezone != "" {
+		if location, err = time.LoadLocation(w.Timezone); err != nil {
+			return windowSchedule{}, fmt.Errorf("invalid timezone %q: %v", w.Timezone, err)
+		}
+	}
+	return windowSchedule{schedule: schedule, location: location}, nil
+}
+
+// contains 判断 t 所在的分钟是否匹配窗口
+func (w windowSchedule) contains(t time.Time) bool {
+	minute := t.In(w.location).Truncate(time.Minute)
+	return w.schedule.Next(minute.Add(-time.Second)).Equal(minute)
+}
+
+func (p DeploymentPolicy) freezeAt(t time.Time) (FreezePeriod, bool) {
+	for _, f := range p.Freezes {
+		if !t.Before(f.Start) && t.Before(f.End) {
+			return f, true
+		}
+	}
+	return FreezePeriod{}, false
+}
+
+func inAnyWindow(windows []windowSchedule, t time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	for _, w := range windows {
+		if w.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate 检查 t 时刻能否发布，不能发布时给出原因和下一个可发布时间
+func (p DeploymentPolicy) Evaluate(t time.Time) (PolicyDecision, error) {
+	windows := make([]windowSchedule, 0, len(p.Windows))
+	for _, w := range p.Windows {
+		ws, err := parseWindow(w)
+		if err != nil {
+			return PolicyDecision{}, err
+		}
+		windows = append(windows, ws)
+	}
+
+	if f, ok := p.freezeAt(t); ok {
+		return PolicyDecision{
+			Reason:      fmt.Sprintf("frozen until %s: %s", f.End.Format(time.RFC3339), f.Reason),
+			NextAllowed: p.nextAllowed(windows, f.End),
+		}, nil
+	}
+	if !inAnyWindow(windows, t) {
+		return PolicyDecision{
+			Reason:      "outside deployment windows",
+			NextAllowed: p.nextAllowed(windows, t),
+		}, nil
+	}
+	return PolicyDecision{Allowed: true, NextAllowed: t}, nil
+}
+
+// nextAllowed 从 t 开始跳过封版期和窗口外的时间
+func (p DeploymentPolicy) nextAllowed(windows []windowSchedule, t time.Time) time.Time {
+	for i := 0; i < maxPolicyIterations; i++ {
+		if f, ok := p.freezeAt(t); ok {
+			t = f.End
+			continue
+		}
+		if inAnyWindow(windows, t) {
+			return t
+		}
+		var next time.Time
+		for _, w := range windows {
+			n := w.schedule.Next(t.In(w.location))
+			if !n.IsZero() && (next.IsZero() || n.Before(next)) {
+				next = n
+			}
+		}
+		if next.IsZero() {
+			return time.Time{}
+		}
+		t = next
+	}
+	return time.Time{}
+}
+
+// LoadPolicy 读取应用环境的发布窗口和封版期
+func LoadPolicy(app, environment string) (DeploymentPolicy, error) {
+	var policy DeploymentPolicy
+	db := shared.GetDB()
+	if err := db.Where("app = ? AND environment = ?", app, environment).Find(&policy.Windows).Error; err != nil {
+		return policy, err
+	}
+	err := db.Where("app = ? AND environment = ?", app, environment).Order("start").Find(&policy.Freezes).Error
+	return policy, err
+}
+
+// LoadPolicyActivity 在工作流中读取发布策略
+func LoadPolicyActivity(ctx context.Context, app, environment string) (DeploymentPolicy, error) {
+	policy, err := LoadPolicy(app, environment)
+	if err != nil {
+		return policy, fmt.Errorf("load policy for %s/%s error: %v", app, environment, err)
+	}
+	return policy, nil
+}
+
+// RecordOverrideActivity 记录管理员绕过发布策略
+func RecordOverrideActivity(ctx context.Context, override PolicyOverride) error {
+	if ctx != nil && activity.IsActivity(ctx) {
+		info := activity.GetInfo(ctx)
+		override.WorkflowID = info.WorkflowExecution.ID
+		override.RunID = info.WorkflowExecution.RunID
+	}
+	if err := shared.GetDB().Create(&override).Error; err != nil {
+		return fmt.Errorf("record policy override error: %v", err)
+	}
+	return nil
+}
+
+// enforcePolicy 在发布前检查环境的发布策略，配置了 WaitForWindow 时用定时器等到下一个窗口
+func enforcePolicy(ctx workflow.Context, config Config, progress *progressTracker) error {
+	logger := workflow.GetLogger(ctx)
+	progress.addSteps(1)
+	progress.enter(StepPolicy)
+
+	for {
+		var policy DeploymentPolicy
+		err := workflow.ExecuteActivity(withStep(ctx, config, StepPolicy), LoadPolicyActivity, config.AppName(), config.Environment).Get(ctx, &policy)
+		if err != nil {
+			progress.fail(err)
+			return err
+		}
+		now := workflow.Now(ctx)
+		decision, err := policy.Evaluate(now)
+		if err != nil {
+			err = temporal.NewNonRetryableApplicationError(err.Error(), ErrTypePolicyViolation, nil)
+			progress.fail(err)
+			return err
+		}
+		if decision.Allowed {
+			return nil
+		}
+
+		if config.Override != nil {
+			progress.logf("policy overridden by %s: %s (%s)", config.Override.Actor, config.Override.Reason, decision.Reason)
+			override := PolicyOverride{
+				App:         config.AppName(),
+				Environment: config.Environment,
+				Version:     config.Version,
+				Actor:       config.Override.Actor,
+				Reason:      config.Override.Reason,
+				Blocked:     decision.Reason,
+			}
+			err := workflow.ExecuteActivity(withStep(ctx, config, StepPolicy), RecordOverrideActivity, override).Get(ctx, nil)
+			if err != nil {
+				progress.fail(err)
+			}
+			return err
+		}
+
+		if !config.WaitForWindow || decision.NextAllowed.IsZero() {
+			err := temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("release to %s blocked: %s", config.Environment, decision.Reason), ErrTypePolicyViolation, nil, decision)
+			progress.fail(err)
+			return err
+		}
+
+		// 等待期间策略可能被修改，醒来后重新检查
+		wait := decision.NextAllowed.Sub(now)
+		progress.logf("%s, waiting %s until %s", decision.Reason, wait, decision.NextAllowed.Format(time.RFC3339))
+		logger.Info("Waiting for deployment window.", "Until", decision.NextAllowed)
+		if err := workflow.Sleep(ctx, wait); err != nil {
+			progress.fail(err)
+			return err
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeploymentPolicyEvaluate(t *testing.T) {
+	// 2024-03-04 是周一
+	at := func(s string) time.Time {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	businessHours := []DeployWindow{{Cron: "* 9-17 * * 1-5"}}
+	freeze := []FreezePeriod{{Start: at("2024-03-04T12:00:00Z"), End: at("2024-03-05T12:00:00Z"), Reason: "quarter close"}}
+
+	tests := []struct {
+		name        string
+		policy      DeploymentPolicy
+		now         time.Time
+		allowed     bool
+		nextAllowed time.Time
+	}{
+		{"no policy", DeploymentPolicy{}, at("2024-03-04T03:00:00Z"), true, at("2024-03-04T03:00:00Z")},
+		{"inside window", DeploymentPolicy{Windows: businessHours}, at("2024-03-04T10:30:15Z"), true, at("2024-03-04T10:30:15Z")},
+		{"last minute of window", DeploymentPolicy{Windows: businessHours}, at("2024-03-04T17:59:00Z"), true, at("2024-03-04T17:59:00Z")},
+		{"after hours", DeploymentPolicy{Windows: businessHours}, at("2024-03-04T18:00:00Z"), false, at("2024-03-05T09:00:00Z")},
+		{"weekend", DeploymentPolicy{Windows: businessHours}, at("2024-03-09T10:00:00Z"), false, at("2024-03-11T09:00:00Z")},
+		{"frozen", DeploymentPolicy{Freezes: freeze}, at("2024-03-04T13:00:00Z"), false, at("2024-03-05T12:00:00Z")},
+		{"frozen then window", DeploymentPolicy{Windows: []DeployWindow{{Cron: "* 9 * * *"}}, Freezes: freeze}, at("2024-03-04T13:00:00Z"), false, at("2024-03-06T09:00:00Z")},
+		{
+			"window in timezone",
+			DeploymentPolicy{Windows: []DeployWindow{{Cron: "* 9-17 * * 1-5", Timezone: "Asia/Shanghai"}}},
+			at("2024-03-04T02:00:00Z"), true, at("2024-03-04T02:00:00Z"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := tt.policy.Evaluate(tt.now)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if decision.Allowed != tt.allowed {
+				t.Errorf("Allowed = %v (%s), want %v", decision.Allowed, decision.Reason, tt.allowed)
+			}
+			if !decision.NextAllowed.Equal(tt.nextAllowed) {
+				t.Errorf("NextAllowed = %v, want %v", decision.NextAllowed, tt.nextAllowed)
+			}
+		})
+	}
+}
+
+func TestDeploymentPolicyInvalidWindow(t *testing.T) {
+	policy := DeploymentPolicy{Windows: []DeployWindow{{Cron: "every day"}}}
+	if _, err := policy.Evaluate(time.Now()); err == nil {
+		t.Error("expected error for invalid cron expression")
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package pkg
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup 让命令运行在独立的进程组中，取消时可以连同子进程一起结束
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signalProcessGroup 向命令所在的进程组发送信号
+func signalProcessGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	return syscall.Kill(-cmd.Process.Pid, sig)
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package pkg
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup Windows 上没有进程组，只结束命令本身
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// signalProcessGroup Windows 不支持 SIGTERM，直接结束进程
+func signalProcessGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	return cmd.Process.Kill()
+}
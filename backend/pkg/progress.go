@@ -0,0 +1,120 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/workflow"
+)
+
+// ProgressQueryName 工作流进度查询名称，API 通过它读取当前阶段和日志
+const ProgressQueryName = "progress"
+
+// 进度中最多保留的日志条数，避免工作流状态和每次查询结果无限增长
+const maxProgressLogs = 200
+
+const (
+	ProgressRunning   = "running"
+	ProgressCompleted = "completed"
+	ProgressFailed    = "failed"
+)
+
+// LogLine 工作流中记录的一行日志
+type LogLine struct {
+	Time    time.Time
+	Stage   string
+	Message string
+}
+
+// Progress 工作流当前进度，由查询处理器返回
+type Progress struct {
+	Stage      string
+	Step       int
+	TotalSteps int
+	Status     string
+	Error      string
+	Logs       []LogLine
+	// 超出 maxProgressLogs 后丢弃的最早日志条数，加上 len(Logs) 为记录过的总条数
+	DroppedLogs int
+	UpdatedAt   time.Time
+}
+
+// ActivityProgress 活动心跳中携带的进度信息
+type ActivityProgress struct {
+	Message string
+	Time    time.Time
+}
+
+// progressTracker 在工作流内维护进度并注册查询处理器
+type progressTracker struct {
+	ctx      workflow.Context
+	progress Progress
+}
+
+func newProgressTracker(ctx workflow.Context, totalSteps int) (*progressTracker, error) {
+	p := &progressTracker{
+		ctx: ctx,
+		progress: Progress{
+			TotalSteps: totalSteps,
+			Status:     ProgressRunning,
+			UpdatedAt:  workflow.Now(ctx),
+		},
+	}
+	err := workflow.SetQueryHandler(ctx, ProgressQueryName, func() (Progress, error) {
+		return p.progress, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("register progress query error: %v", err)
+	}
+	return p, nil
+}
+
+// addSteps 增加总步骤数，用于运行中才能确定的步骤
+func (p *progressTracker) addSteps(n int) {
+	p.progress.TotalSteps += n
+}
+
+// enter 切换到下一个阶段
+func (p *progressTracker) enter(stage string) {
+	p.progress.Stage = stage
+	p.progress.Step++
+	p.logf("stage %s started", stage)
+}
+
+func (p *progressTracker) logf(format string, args ...interface{}) {
+	now := workflow.Now(p.ctx)
+	p.progress.Logs = append(p.progress.Logs, LogLine{
+		Time:    now,
+		Stage:   p.progress.Stage,
+		Message: fmt.Sprintf(format, args...),
+	})
+	if len(p.progress.Logs) > maxProgressLogs {
+		p.progress.Logs = p.progress.Logs[1:]
+		p.progress.DroppedLogs++
+	}
+	p.progress.UpdatedAt = now
+}
+
+func (p *progressTracker) fail(err error) {
+	p.progress.Status = ProgressFailed
+	p.progress.Error = err.Error()
+	p.logf("stage %s failed: %v", p.progress.Stage, err)
+}
+
+func (p *progressTracker) complete() {
+	p.progress.Status = ProgressCompleted
+	p.logf("workflow completed")
+}
+
+// reportProgress 通过心跳上报活动进度，非活动上下文（如单元测试）中忽略
+func reportProgress(ctx context.Context, format string, args ...interface{}) {
+	if ctx == nil || !activity.IsActivity(ctx) {
+		return
+	}
+	activity.RecordHeartbeat(ctx, ActivityProgress{
+		Message: fmt.Sprintf(format, args...),
+		Time:    time.Now(),
+	})
+}
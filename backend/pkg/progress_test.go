@@ -0,0 +1,140 @@
+package pkg
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+// progressTestWorkflow 进入两个阶段，中间等待一分钟以便测试在运行中查询进度
+func progressTestWorkflow(ctx workflow.Context, failWith string) error {
+	progress, err := newProgressTracker(ctx, 2)
+	if err != nil {
+		return err
+	}
+	progress.enter("build")
+	progress.logf("building %s", "app")
+	if err := workflow.Sleep(ctx, time.Minute); err != nil {
+		return err
+	}
+	progress.enter("upload")
+	if failWith != "" {
+		err := errors.New(failWith)
+		progress.fail(err)
+		return err
+	}
+	progress.complete()
+	return nil
+}
+
+func queryProgress(t *testing.T, env *testsuite.TestWorkflowEnvironment) Progress {
+	t.Helper()
+	value, err := env.QueryWorkflow(ProgressQueryName)
+	if err != nil {
+		t.Fatalf("query progress: %v", err)
+	}
+	var progress Progress
+	if err := value.Get(&progress); err != nil {
+		t.Fatalf("decode progress: %v", err)
+	}
+	return progress
+}
+
+func logMessages(progress Progress) []string {
+	var messages []string
+	for _, line := range progress.Logs {
+		messages = append(messages, line.Stage+": "+line.Message)
+	}
+	return messages
+}
+
+func TestProgressTracker(t *testing.T) {
+	tests := []struct {
+		name       string
+		failWith   string
+		wantStatus string
+		wantError  string
+		wantLast   string
+	}{
+		{"completed", "", ProgressCompleted, "", "upload: workflow completed"},
+		{"failed", "upload refused", ProgressFailed, "upload refused", "upload: stage upload failed: upload refused"},
+	}
+
+	for _, tt := range tests {
+		var suite testsuite.WorkflowTestSuite
+		env := suite.NewTestWorkflowEnvironment()
+
+		var running Progress
+		env.RegisterDelayedCallback(func() {
+			running = queryProgress(t, env)
+		}, 30*time.Second)
+		env.ExecuteWorkflow(progressTestWorkflow, tt.failWith)
+
+		if !env.IsWorkflowCompleted() {
+			t.Fatalf("%s: workflow did not complete", tt.name)
+		}
+		if err := env.GetWorkflowError(); (err != nil) != (tt.failWith != "") {
+			t.Errorf("%s: workflow error = %v", tt.name, err)
+		}
+
+		wantRunning := []string{"build: stage build started", "build: building app"}
+		if running.Stage != "build" || running.Step != 1 || running.TotalSteps != 2 || running.Status != ProgressRunning {
+			t.Errorf("%s: running progress = %s step %d/%d %s, want build step 1/2 running",
+				tt.name, running.Stage, running.Step, running.TotalSteps, running.Status)
+		}
+		if got := logMessages(running); !reflect.DeepEqual(got, wantRunning) {
+			t.Errorf("%s: running logs = %q, want %q", tt.name, got, wantRunning)
+		}
+
+		final := queryProgress(t, env)
+		if final.Stage != "upload" || final.Step != 2 || final.TotalSteps != 2 {
+			t.Errorf("%s: final progress = %s step %d/%d, want upload step 2/2", tt.name, final.Stage, final.Step, final.TotalSteps)
+		}
+		if final.Status != tt.wantStatus || final.Error != tt.wantError {
+			t.Errorf("%s: final status = %s %q, want %s %q", tt.name, final.Status, final.Error, tt.wantStatus, tt.wantError)
+		}
+		logs := logMessages(final)
+		if len(logs) != 4 || logs[3] != tt.wantLast {
+			t.Errorf("%s: final logs = %q, want 4 lines ending with %q", tt.name, logs, tt.wantLast)
+		}
+		// 日志时间使用工作流时间，等待之后的日志晚一分钟
+		if got := final.Logs[2].Time.Sub(final.Logs[0].Time); got != time.Minute {
+			t.Errorf("%s: upload logged %v after build, want 1m", tt.name, got)
+		}
+		if !final.UpdatedAt.Equal(final.Logs[3].Time) {
+			t.Errorf("%s: UpdatedAt = %v, want last log time %v", tt.name, final.UpdatedAt, final.Logs[3].Time)
+		}
+	}
+}
+
+func TestProgressTrackerLogLimit(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.ExecuteWorkflow(func(ctx workflow.Context) error {
+		progress, err := newProgressTracker(ctx, 1)
+		if err != nil {
+			return err
+		}
+		progress.enter("build")
+		for i := 0; i < maxProgressLogs+10; i++ {
+			progress.logf("line %d", i)
+		}
+		return nil
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("workflow error = %v", err)
+	}
+
+	// enter 记录一行，之后又记录了 maxProgressLogs+10 行
+	progress := queryProgress(t, env)
+	if len(progress.Logs) != maxProgressLogs || progress.DroppedLogs != 11 {
+		t.Fatalf("kept %d logs, dropped %d, want %d and 11", len(progress.Logs), progress.DroppedLogs, maxProgressLogs)
+	}
+	if got, want := progress.Logs[0].Message, "line 10"; got != want {
+		t.Errorf("oldest kept log = %q, want %q", got, want)
+	}
+}
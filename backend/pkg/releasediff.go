@@ -0,0 +1,180 @@
+package pkg
+
+import (
+	"errors"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// ValueChange 某个字段在两次发布间的取值
+type ValueChange struct {
+	Name    string `json:"name"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Changed bool   `json:"changed"`
+}
+
+// ConfigChange 两次发布渲染出的配置文件差异，没有渲染记录时 Available 为 false
+type ConfigChange struct {
+	Available    bool   `json:"available"`
+	FromChecksum string `json:"from_checksum"`
+	ToChecksum   string `json:"to_checksum"`
+	Changed      bool   `json:"changed"`
+	Diff         string `json:"diff,omitempty"`
+}
+
+// ReleaseDiff 两次发布之间的变化
+type ReleaseDiff struct {
+	From Release `json:"from"`
+	To   Release `json:"to"`
+	// From 比 To 新时为 true，此时 Commits 为回退掉的提交
+	Reverse   bool             `json:"reverse"`
+	Commits   []ChangelogEntry `json:"commits"`
+	Config    ConfigChange     `json:"config"`
+	Artifacts []ValueChange    `json:"artifacts"`
+	Params    []ValueChange    `json:"params"`
+	// 缺少记录而无法比较的部分
+	Missing []string `json:"missing,omitempty"`
+}
+
+// mergeChangelogs 合并多次发布的变更日志，changelogs 按新到旧排列，遇到 base 提交后停止并去重
+func mergeChangelogs(changelogs []Changelog, base string) []ChangelogEntry {
+	seen := map[string]bool{}
+	var entries []ChangelogEntry
+	for _, changelog := range changelogs {
+		for _, entry := range changelog.Entries {
+			if base != "" && entry.Hash == base {
+				break
+			}
+			if seen[entry.Hash] {
+				continue
+			}
+			seen[entry.Hash] = true
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// diffValues 按名称排序比较两组取值
+func diffValues(from, to map[string]string) []ValueChange {
+	names := map[string]bool{}
+	for name := range from {
+		names[name] = true
+	}
+	for name := range to {
+		names[name] = true
+	}
+	changes := make([]ValueChange, 0, len(names))
+	for name := range names {
+		changes = append(changes, ValueChange{Name: name, From: from[name], To: to[name], Changed: from[name] != to[name]})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+// artifactChecksums 返回用于比较的产物校验和
+func artifactChecksums(artifact Artifact) map[string]string {
+	return map[string]string{
+		"binary": artifact.BinaryChecksum,
+		"config": artifact.ConfigChecksum,
+	}
+}
+
+// FindArtifact 查找发布对应的产物记录，没有时返回 false
+func FindArtifact(db *gorm.DB, release Release) (Artifact, bool, error) {
+	var artifact Artifact
+	err := db.Where("app = ? AND environment = ? AND version = ?", release.App, release.Environment, release.Version).First(&artifact).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return artifact, false, nil
+	}
+	return artifact, err == nil, err
+}
+
+// findRenderedConfig 查找发布对应的最近一次配置渲染结果，内容按当前的敏感变量脱敏后再用于对比
+func findRenderedConfig(db *gorm.DB, release Release) (RenderedConfig, bool, error) {
+	var rendered RenderedConfig
+	err := db.Where("app = ? AND environment = ? AND version = ?", release.App, release.Environment, release.Version).
+		Order("id desc").First(&rendered).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return rendered, false, nil
+	}
+	if err != nil {
+		return rendered, false, err
+	}
+	if err := RedactRenderedConfig(db, &rendered); err != nil {
+		return rendered, false, err
+	}
+	return rendered, true, nil
+}
+
+// releaseCommits 合并两次发布之间各次发布的变更日志
+func releaseCommits(db *gorm.DB, older, newer Release) ([]ChangelogEntry, error) {
+	var versions []string
+	err := db.Model(&Release{}).
+		Where("app = ? AND id > ? AND id <= ?", newer.App, older.ID, newer.ID).
+		Order("id desc").Pluck("version", &versions).Error
+	if err != nil {
+		return nil, err
+	}
+	var changelogs []Changelog
+	err = db.Where("app = ? AND version IN ?", newer.App, append(versions, newer.Version)).Order("id desc").Find(&changelogs).Error
+	if err != nil {
+		return nil, err
+	}
+	return mergeChangelogs(changelogs, older.Commit), nil
+}
+
+// DiffReleases 比较同一应用的两次发布：提交、配置文件、产物校验和以及构建参数
+func DiffReleases(db *gorm.DB, from, to Release) (ReleaseDiff, error) {
+	diff := ReleaseDiff{From: from, To: to, Reverse: from.ID > to.ID}
+	older, newer := from, to
+	if diff.Reverse {
+		older, newer = to, from
+	}
+
+	commits, err := releaseCommits(db, older, newer)
+	if err != nil {
+		return diff, err
+	}
+	diff.Commits = commits
+	if len(commits) == 0 && older.Commit != newer.Commit {
+		diff.Missing = append(diff.Missing, "commits")
+	}
+
+	fromConfig, fromOK, err := findRenderedConfig(db, from)
+	if err != nil {
+		return diff, err
+	}
+	toConfig, toOK, err := findRenderedConfig(db, to)
+	if err != nil {
+		return diff, err
+	}
+	if fromOK && toOK {
+		diff.Config = ConfigChange{
+			Available:    true,
+			FromChecksum: fromConfig.Checksum,
+			ToChecksum:   toConfig.Checksum,
+			Changed:      fromConfig.Checksum != toConfig.Checksum,
+			Diff:         unifiedDiff(from.Version, to.Version, fromConfig.Content, toConfig.Content),
+		}
+	} else {
+		diff.Missing = append(diff.Missing, "rendered config")
+	}
+
+	fromArtifact, fromOK, err := FindArtifact(db, from)
+	if err != nil {
+		return diff, err
+	}
+	toArtifact, toOK, err := FindArtifact(db, to)
+	if err != nil {
+		return diff, err
+	}
+	if !fromOK || !toOK {
+		diff.Missing = append(diff.Missing, "artifacts")
+	}
+	diff.Artifacts = diffValues(artifactChecksums(fromArtifact), artifactChecksums(toArtifact))
+	diff.Params = diffValues(fromArtifact.Params, toArtifact.Params)
+	return diff, nil
+}
@@ -0,0 +1,133 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMergeChangelogs(t *testing.T) {
+	changelogs := []Changelog{
+		{Version: "v3", Entries: []ChangelogEntry{{Hash: "e"}, {Hash: "d"}, {Hash: "c"}}},
+		{Version: "v2", Entries: []ChangelogEntry{{Hash: "d"}, {Hash: "c"}, {Hash: "b"}, {Hash: "a"}}},
+	}
+
+	tests := []struct {
+		name string
+		base string
+		want []string
+	}{
+		{"stops at base", "b", []string{"e", "d", "c"}},
+		{"no base", "", []string{"e", "d", "c", "b", "a"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, entry := range mergeChangelogs(changelogs, tt.base) {
+			got = append(got, entry.Hash)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: mergeChangelogs() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiffValues(t *testing.T) {
+	got := diffValues(
+		map[string]string{"commit": "abc", "deploy_mode": "restart", "tag": "v1"},
+		map[string]string{"commit": "def", "deploy_mode": "restart", "hosts": "a,b"},
+	)
+	want := []ValueChange{
+		{Name: "commit", From: "abc", To: "def", Changed: true},
+		{Name: "deploy_mode", From: "restart", To: "restart"},
+		{Name: "hosts", To: "a,b", Changed: true},
+		{Name: "tag", From: "v1", Changed: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffValues() = %+v, want %+v", got, want)
+	}
+}
+
+func TestFileChecksum(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app")
+	if err := os.WriteFile(name, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum, size, err := fileChecksum(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != checksum("hello") || size != 5 {
+		t.Errorf("fileChecksum() = %s, %d", sum, size)
+	}
+	if _, _, err := fileChecksum(name + ".missing"); err == nil {
+		t.Errorf("fileChecksum() of a missing file should fail")
+	}
+}
+
+func TestVerifyBinary(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app")
+	if err := os.WriteFile(name, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"not a promotion", Config{BinaryPath: name + ".missing"}, false},
+		{"matches", Config{BinaryPath: name, BinaryChecksum: checksum("hello")}, false},
+		{"rebuilt since", Config{BinaryPath: name, BinaryChecksum: checksum("world")}, true},
+		{"missing binary", Config{BinaryPath: name + ".missing", BinaryChecksum: checksum("hello")}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verifyBinary(tt.config); (err != nil) != tt.wantErr {
+				t.Errorf("verifyBinary() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestArtifactStore(t *testing.T) {
+	dir := t.TempDir()
+	defer func(old string) { ArtifactDir = old }(ArtifactDir)
+	ArtifactDir = filepath.Join(dir, "store")
+
+	built := filepath.Join(dir, "build", "app")
+	if err := os.MkdirAll(filepath.Dir(built), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(built, []byte("hello"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	sum := checksum("hello")
+	if err := storeArtifact(built, sum); err != nil {
+		t.Fatalf("storeArtifact() error = %v", err)
+	}
+
+	// 在另一台 Worker 的工作目录中取回
+	restored := filepath.Join(dir, "other-worker", "build", "app")
+	if err := restoreArtifact(sum, restored); err != nil {
+		t.Fatalf("restoreArtifact() error = %v", err)
+	}
+	if content, err := os.ReadFile(restored); err != nil || string(content) != "hello" {
+		t.Errorf("restored content = %q, %v", content, err)
+	}
+	if info, err := os.Stat(restored); err != nil || info.Mode().Perm()&0100 == 0 {
+		t.Errorf("restored binary is not executable: %v", err)
+	}
+
+	if err := restoreArtifact(checksum("world"), restored); err == nil {
+		t.Errorf("restoreArtifact() of a missing entry should fail")
+	}
+	if err := os.WriteFile(artifactStorePath(sum), []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreArtifact(sum, restored); err == nil {
+		t.Errorf("restoreArtifact() of a tampered entry should fail")
+	}
+}
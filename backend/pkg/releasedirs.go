@@ -0,0 +1,244 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// ReleaseLayout 主机上的版本目录布局：<Root>/releases/<version>/，<Root>/current 指向生效版本
+type ReleaseLayout struct {
+	Enabled bool
+	// 为空时使用 Config.ECSUploadPath
+	Root string
+	// 保留的版本数量，生效版本始终保留
+	Keep int
+}
+
+// HostReleases 主机上的版本目录，按从新到旧排序
+type HostReleases struct {
+	Host     string
+	Current  string
+	Releases []string
+}
+
+func (l ReleaseLayout) withDefaults(config Config) ReleaseLayout {
+	if l.Root == "" {
+		l.Root = config.ECSUploadPath
+	}
+	if l.Keep <= 0 {
+		l.Keep = 5
+	}
+	return l
+}
+
+func (l ReleaseLayout) releasesDir() string {
+	return path.Join(l.Root, "releases")
+}
+
+func (l ReleaseLayout) releaseDir(version string) string {
+	return path.Join(l.releasesDir(), version)
+}
+
+func (l ReleaseLayout) currentLink() string {
+	return path.Join(l.Root, "current")
+}
+
+func (l ReleaseLayout) pidFile() string {
+	return path.Join(l.Root, "app.pid")
+}
+
+// startCommand 在 current 目录下启动应用，日志和 PID 文件放在 Root 下，不随版本切换。
+// cd 会解析链接，工作目录是具体的版本目录，因此用经过 current 的绝对路径启动，
+// socket-handoff 模式下 tableflip 按 os.Args[0] 重新执行时才会启动新版本
+func (l ReleaseLayout) startCommand(binary string) string {
+	return fmt.Sprintf("cd %s && (nohup %s > %s 2>&1 & echo $! > %s)",
+		shellQuote(l.currentLink()), shellQuote(path.Join(l.currentLink(), binary)),
+		shellQuote(path.Join(l.Root, "app.log")), shellQuote(l.pidFile()))
+}
+
+// stopCommand 按 PID 文件停止 startCommand 启动的进程，进程不存在时不报错
+func (l ReleaseLayout) stopCommand() string {
+	pidFile := shellQuote(l.pidFile())
+	return fmt.Sprintf("test -f %s && kill -TERM $(cat %s) && rm -f %s || true", pidFile, pidFile, pidFile)
+}
+
+// validateReleaseVersion 版本号会拼进远程路径，不允许路径分隔符
+func validateReleaseVersion(version string) error {
+	if version == "" || version == "." || version == ".." || strings.ContainsAny(version, "/\\") {
+		return temporal.NewNonRetryableApplicationError(fmt.Sprintf("invalid release version %q", version), ErrTypeRemoteCommand, nil)
+	}
+	return nil
+}
+
+// releasesToPrune 返回需要删除的旧版本，releases 按从新到旧排序
+func releasesToPrune(releases []string, current string, keep int) []string {
+	var prune []string
+	kept := 0
+	for _, release := range releases {
+		if release == current {
+			continue
+		}
+		// 生效版本占用一个保留名额
+		if kept < keep-1 {
+			kept++
+			continue
+		}
+		prune = append(prune, release)
+	}
+	return prune
+}
+
+// StageReleaseActivity 将打包产物上传到主机的版本目录并解压
+func StageReleaseActivity(ctx context.Context, config Config) error {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+
+	if err := validateReleaseVersion(config.Version); err != nil {
+		return err
+	}
+	layout := config.Releases.withDefaults(config)
+	dir := layout.releaseDir(config.Version)
+	target := fmt.Sprintf("%s@%s", config.ECSUser, config.ECSServer)
+
+	if _, err := runRemote(ctx, config, logs, "mkdir -p "+shellQuote(dir)); err != nil {
+		return err
+	}
+
+	archives := []string{
+		fmt.Sprintf("%s_binary.tar.gz", config.LocalPath),
+		fmt.Sprintf("%s_config.tar.gz", config.LocalPath),
+	}
+	var extract []string
+	for _, archive := range archives {
+		logs.Printf("Uploading %s to %s:%s", archive, config.ECSServer, dir)
+		reportProgress(ctx, "uploading %s to %s", filepath.Base(archive), config.ECSServer)
+		cmd := exec.Command("scp", archive, target+":"+dir+"/")
+		cmd.Stdout = logs
+		cmd.Stderr = logs
+		if err := runCommand(ctx, cmd); err != nil {
+			return temporal.NewApplicationError(fmt.Sprintf("upload %s error: %v", archive, err), ErrTypeRemoteCommand)
+		}
+		extract = append(extract, "tar -xzf "+shellQuote(filepath.Base(archive)))
+	}
+
+	_, err := runRemote(ctx, config, logs, "cd "+shellQuote(dir)+" && "+strings.Join(extract, " && "))
+	if err != nil {
+		return err
+	}
+	logs.Printf("Staged release %s on %s", config.Version, config.ECSServer)
+	return nil
+}
+
+// ActivateReleaseActivity 原子地将 current 链接切换到 Config.Version，返回切换前的版本
+func ActivateReleaseActivity(ctx context.Context, config Config) (string, error) {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+
+	if err := validateReleaseVersion(config.Version); err != nil {
+		return "", err
+	}
+	layout := config.Releases.withDefaults(config)
+	current := layout.currentLink()
+	tmp := current + ".tmp"
+
+	output, err := runRemote(ctx, config, logs, fmt.Sprintf("readlink %s || true", shellQuote(current)))
+	if err != nil {
+		return "", err
+	}
+	var previous string
+	if link := strings.TrimSpace(output); link != "" {
+		previous = path.Base(link)
+	}
+
+	// 先创建临时链接再 rename，切换过程中 current 始终有效
+	script := fmt.Sprintf("test -d %s && ln -sfn %s %s && mv -Tf %s %s",
+		shellQuote(layout.releaseDir(config.Version)),
+		shellQuote(path.Join("releases", config.Version)), shellQuote(tmp),
+		shellQuote(tmp), shellQuote(current))
+	logs.Printf("Activating %s on %s (previous %q)", config.Version, config.ECSServer, previous)
+	reportProgress(ctx, "activating release %s", config.Version)
+	if _, err := runRemote(ctx, config, logs, script); err != nil {
+		return previous, err
+	}
+	return previous, nil
+}
+
+// ListReleasesActivity 列出主机上的版本目录和当前生效版本
+func ListReleasesActivity(ctx context.Context, config Config) (HostReleases, error) {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+	return listReleases(ctx, config, logs)
+}
+
+// ListReleasesWorkflow 并行列出每台发布主机上的版本目录，供 API 同步查询
+func ListReleasesWorkflow(ctx workflow.Context, config Config) ([]HostReleases, error) {
+	hosts := config.releaseHosts()
+	futures := make([]workflow.Future, len(hosts))
+	for i, host := range hosts {
+		futures[i] = workflow.ExecuteActivity(withStep(ctx, config, StepListReleases), ListReleasesActivity, config.forHost(host))
+	}
+	results := make([]HostReleases, len(hosts))
+	for i, future := range futures {
+		if err := future.Get(ctx, &results[i]); err != nil {
+			return nil, fmt.Errorf("list releases on %s: %v", hosts[i], err)
+		}
+	}
+	return results, nil
+}
+
+func listReleases(ctx context.Context, config Config, logs *activityLog) (HostReleases, error) {
+	layout := config.Releases.withDefaults(config)
+	result := HostReleases{Host: config.ECSServer}
+
+	output, err := runRemote(ctx, config, logs, fmt.Sprintf("readlink %s || true; echo ---; ls -1t %s 2>/dev/null || true",
+		shellQuote(layout.currentLink()), shellQuote(layout.releasesDir())))
+	if err != nil {
+		return result, err
+	}
+	link, list, _ := strings.Cut(output, "---\n")
+	if link = strings.TrimSpace(link); link != "" {
+		result.Current = path.Base(link)
+	}
+	for _, line := range strings.Split(list, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			result.Releases = append(result.Releases, line)
+		}
+	}
+	return result, nil
+}
+
+// PruneReleasesActivity 删除超出保留数量的旧版本目录，返回被删除的版本
+func PruneReleasesActivity(ctx context.Context, config Config) ([]string, error) {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+
+	releases, err := listReleases(ctx, config, logs)
+	if err != nil {
+		return nil, err
+	}
+	layout := config.Releases.withDefaults(config)
+	prune := releasesToPrune(releases.Releases, releases.Current, layout.Keep)
+	if len(prune) == 0 {
+		return nil, nil
+	}
+
+	var dirs []string
+	for _, release := range prune {
+		if err := validateReleaseVersion(release); err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, shellQuote(layout.releaseDir(release)))
+	}
+	logs.Printf("Pruning %v on %s", prune, config.ECSServer)
+	if _, err := runRemote(ctx, config, logs, "rm -rf "+strings.Join(dirs, " ")); err != nil {
+		return nil, err
+	}
+	return prune, nil
+}
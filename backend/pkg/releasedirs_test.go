@@ -0,0 +1,42 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReleasesToPrune(t *testing.T) {
+	releases := []string{"v5", "v4", "v3", "v2", "v1"}
+	tests := []struct {
+		name    string
+		current string
+		keep    int
+		want    []string
+	}{
+		{name: "current is newest", current: "v5", keep: 3, want: []string{"v2", "v1"}},
+		{name: "current is old", current: "v1", keep: 3, want: []string{"v3", "v2"}},
+		{name: "keep everything", current: "v5", keep: 5, want: nil},
+		{name: "keep only current", current: "v3", keep: 1, want: []string{"v5", "v4", "v2", "v1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := releasesToPrune(releases, tt.current, tt.keep); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("releasesToPrune() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReleaseLayoutCommands(t *testing.T) {
+	config := Config{ECSUploadPath: "/opt/app", BinaryPath: "build/myapp"}
+	layout := ReleaseLayout{Enabled: true}.withDefaults(config)
+
+	start := layout.startCommand(config.binaryName())
+	if want := "cd '/opt/app/current' && (nohup '/opt/app/current/myapp' > '/opt/app/app.log' 2>&1 & echo $! > '/opt/app/app.pid')"; start != want {
+		t.Errorf("startCommand() = %s, want %s", start, want)
+	}
+	stop := layout.stopCommand()
+	if want := "test -f '/opt/app/app.pid' && kill -TERM $(cat '/opt/app/app.pid') && rm -f '/opt/app/app.pid' || true"; stop != want {
+		t.Errorf("stopCommand() = %s, want %s", stop, want)
+	}
+}
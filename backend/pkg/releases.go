@@ -0,0 +1,120 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/workflow"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"temporal-aone/backend/shared"
+)
+
+// 发布记录状态
+const (
+	ReleaseRunning   = "running"
+	ReleaseSucceeded = "succeeded"
+	ReleaseFailed    = "failed"
+	// 金丝雀分析未通过后已回滚的主机
+	ReleaseRolledBack = "rolled_back"
+)
+
+// Release 一次发布的记录，由工作流通过活动写入；未指定环境的发布 Environment 为空
+type Release struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	App         string `gorm:"size:128;index:idx_release_app_env" json:"app"`
+	Environment string `gorm:"size:64;index:idx_release_app_env" json:"environment"`
+	Version     string `gorm:"size:128;index" json:"version"`
+	Status      string `gorm:"size:32" json:"status"`
+	WorkflowID  string `gorm:"size:255;uniqueIndex:idx_release_run" json:"workflow_id"`
+	RunID       string `gorm:"size:64;uniqueIndex:idx_release_run" json:"run_id"`
+	Error       string `gorm:"type:text" json:"error,omitempty"`
+	// 由晋级等父工作流发起时的父工作流 ID
+	ParentWorkflowID string `gorm:"size:255" json:"parent_workflow_id,omitempty"`
+
+	Commit    string   `gorm:"size:64" json:"commit"`
+	Changelog string   `gorm:"type:longtext" json:"changelog,omitempty"`
+	Hosts     []string `gorm:"type:text;serializer:json" json:"hosts"`
+	// 发起发布的操作人，webhook 触发时为推送者
+	Actor string `gorm:"size:128" json:"actor"`
+
+	// 构建产物信息，晋级到下一个环境时复用
+	RepoURL        string `gorm:"size:512" json:"repo_url"`
+	Tag            string `gorm:"size:255" json:"tag"`
+	BinaryPath     string `gorm:"size:512" json:"binary_path"`
+	ConfigFilePath string `gorm:"size:512" json:"config_file_path"`
+	LocalPath      string `gorm:"size:512" json:"local_path"`
+
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// newRelease 根据工作流信息和配置生成发布记录
+func newRelease(ctx workflow.Context, config Config) Release {
+	info := workflow.GetInfo(ctx)
+	release := Release{
+		App:            config.AppName(),
+		Environment:    config.Environment,
+		Version:        config.Version,
+		Status:         ReleaseRunning,
+		Commit:         config.Commit,
+		Hosts:          config.releaseHosts(),
+		Actor:          config.Actor,
+		WorkflowID:     info.WorkflowExecution.ID,
+		RunID:          info.WorkflowExecution.RunID,
+		RepoURL:        config.RepoURL,
+		Tag:            config.Tag,
+		BinaryPath:     config.BinaryPath,
+		ConfigFilePath: config.ConfigFilePath,
+		LocalPath:      config.LocalPath,
+		StartedAt:      workflow.Now(ctx),
+	}
+	if info.ParentWorkflowExecution != nil {
+		release.ParentWorkflowID = info.ParentWorkflowExecution.ID
+	}
+	return release
+}
+
+// finish 根据发布结果更新状态
+func (r Release) finish(ctx workflow.Context, err error) Release {
+	now := workflow.Now(ctx)
+	r.FinishedAt = &now
+	r.Status = ReleaseSucceeded
+	if err != nil {
+		r.Status = ReleaseFailed
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// RecordReleaseActivity 写入或更新发布记录，同一次工作流运行只有一条记录；
+// 首次写入时附上构建该版本时生成的变更日志
+func RecordReleaseActivity(ctx context.Context, release Release) (Release, error) {
+	db := shared.GetDB()
+	if release.Changelog == "" {
+		var changelog Changelog
+		err := db.Where("app = ? AND version = ?", release.App, release.Version).First(&changelog).Error
+		if err == nil {
+			release.Changelog = changelog.Markdown
+			if release.Commit == "" {
+				release.Commit = changelog.ToCommit
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return release, fmt.Errorf("load changelog error: %v", err)
+		}
+	}
+
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "workflow_id"}, {Name: "run_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"status", "error", "finished_at", "updated_at"}),
+	}).Create(&release).Error
+	if err != nil {
+		return release, fmt.Errorf("record release %s error: %v", release.Version, err)
+	}
+	return release, nil
+}
@@ -0,0 +1,173 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"gorm.io/gorm"
+
+	"temporal-aone/backend/shared"
+)
+
+// ErrTypeRenderConfig 模板语法错误或缺少变量，重试没有意义
+const ErrTypeRenderConfig = "RenderConfigError"
+
+// redactedValue 敏感变量在记录的渲染结果和差异中的替换值
+const redactedValue = "******"
+
+// AppName 返回应用名，未指定时使用仓库名
+func (c Config) AppName() string {
+	if c.App != "" {
+		return c.App
+	}
+	return strings.TrimSuffix(getRepoName(c.RepoURL), ".git")
+}
+
+// renderDir 渲染结果的目录，目录下保持 ConfigFilePath 的相对路径，打包后解压位置与未渲染时一致
+func (c Config) renderDir() string {
+	return fmt.Sprintf("%s_rendered_%s", c.LocalPath, c.Environment)
+}
+
+// renderTemplate 用环境变量渲染配置模板，模板中通过 {{ .KEY }} 引用变量，缺少变量时报错
+func renderTemplate(name, content string, vars map[string]string) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(content)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, vars); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// loadEnvironmentVariables 读取应用在某个环境下的全部变量，同时返回敏感变量的值
+func loadEnvironmentVariables(db *gorm.DB, app, environment string) (map[string]string, []string, error) {
+	var rows []EnvironmentVariable
+	err := db.Where("app = ? AND environment = ?", app, environment).Find(&rows).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	vars := make(map[string]string, len(rows))
+	var secrets []string
+	for _, row := range rows {
+		vars[row.Key] = row.Value
+		if row.Secret {
+			secrets = append(secrets, row.Value)
+		}
+	}
+	return vars, secrets, nil
+}
+
+// redactSecrets 把内容中出现的敏感变量值替换为 redactedValue，较长的值先替换，避免只替换掉一部分
+func redactSecrets(content string, secrets []string) string {
+	sorted := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		if secret != "" {
+			sorted = append(sorted, secret)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, secret := range sorted {
+		content = strings.ReplaceAll(content, secret, redactedValue)
+	}
+	return content
+}
+
+// RedactRenderedConfig 用当前的敏感变量脱敏渲染结果和差异，用于读取脱敏之前记录的数据
+func RedactRenderedConfig(db *gorm.DB, rendered *RenderedConfig) error {
+	_, secrets, err := loadEnvironmentVariables(db, rendered.App, rendered.Environment)
+	if err != nil {
+		return err
+	}
+	rendered.Content = redactSecrets(rendered.Content, secrets)
+	rendered.Diff = redactSecrets(rendered.Diff, secrets)
+	return nil
+}
+
+func checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// RenderConfigActivity 按 Config.Environment 渲染配置模板，与上一次渲染结果对比后记录到数据库。
+// 数据库中的内容和差异对敏感变量脱敏，返回值只含校验和与元数据，避免明文进入工作流历史
+func RenderConfigActivity(ctx context.Context, config Config) (RenderedConfig, error) {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+
+	app := config.AppName()
+	rendered := RenderedConfig{
+		App:          app,
+		Environment:  config.Environment,
+		Version:      config.Version,
+		TemplatePath: config.ConfigFilePath,
+	}
+	if ctx != nil && activity.IsActivity(ctx) {
+		info := activity.GetInfo(ctx)
+		rendered.WorkflowID = info.WorkflowExecution.ID
+		rendered.RunID = info.WorkflowExecution.RunID
+	}
+
+	db := shared.GetDB()
+	vars, secrets, err := loadEnvironmentVariables(db, app, config.Environment)
+	if err != nil {
+		return rendered, fmt.Errorf("load variables for %s/%s error: %v", app, config.Environment, err)
+	}
+	logs.Printf("Rendering %s for %s/%s with %d variables", config.ConfigFilePath, app, config.Environment, len(vars))
+	reportProgress(ctx, "rendering config for %s", config.Environment)
+
+	tmpl, err := os.ReadFile(config.ConfigFilePath)
+	if err != nil {
+		return rendered, fmt.Errorf("read config template error: %v", err)
+	}
+	content, err := renderTemplate(filepath.Base(config.ConfigFilePath), string(tmpl), vars)
+	if err != nil {
+		return rendered, temporal.NewNonRetryableApplicationError(fmt.Sprintf("render config error: %v", err), ErrTypeRenderConfig, nil)
+	}
+	rendered.Checksum = checksum(content)
+
+	rendered.OutputPath = filepath.Join(config.renderDir(), config.ConfigFilePath)
+	if err := os.MkdirAll(filepath.Dir(rendered.OutputPath), 0755); err != nil {
+		return rendered, fmt.Errorf("create render dir error: %v", err)
+	}
+	if err := os.WriteFile(rendered.OutputPath, []byte(content), 0644); err != nil {
+		return rendered, fmt.Errorf("write rendered config error: %v", err)
+	}
+
+	var previous RenderedConfig
+	err = db.Where("app = ? AND environment = ?", app, config.Environment).Order("id desc").First(&previous).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return rendered, err
+	}
+	// 之前的记录可能是脱敏前写入的，对比前同样脱敏
+	rendered.Content = redactSecrets(content, secrets)
+	rendered.Diff = unifiedDiff(previous.Version, rendered.Version, redactSecrets(previous.Content, secrets), rendered.Content)
+	// 只改了敏感变量时脱敏后的差异为空，按校验和判断是否变化
+	rendered.Changed = rendered.Checksum != previous.Checksum
+	if rendered.Diff != "" {
+		logs.Printf("%s", rendered.Diff)
+	} else if rendered.Changed {
+		logs.Printf("Only secret values changed since %s", previous.Version)
+	} else {
+		logs.Printf("Rendered config unchanged since %s", previous.Version)
+	}
+
+	if err := db.Create(&rendered).Error; err != nil {
+		return rendered, fmt.Errorf("record rendered config error: %v", err)
+	}
+	rendered.Content = ""
+	rendered.Diff = ""
+	return rendered, nil
+}
@@ -0,0 +1,61 @@
+package pkg
+
+import "testing"
+
+func TestRenderTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		vars    map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"no variables", "port: 8080\n", nil, "port: 8080\n", false},
+		{"substitute", "db: {{ .DB_HOST }}:{{ .DB_PORT }}\n", map[string]string{"DB_HOST": "10.0.0.1", "DB_PORT": "3306"}, "db: 10.0.0.1:3306\n", false},
+		{"missing variable", "db: {{ .DB_HOST }}\n", map[string]string{}, "", true},
+		{"syntax error", "db: {{ .DB_HOST \n", map[string]string{"DB_HOST": "x"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderTemplate("config.yaml", tt.content, tt.vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renderTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("renderTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderDir(t *testing.T) {
+	config := Config{LocalPath: "/tmp/app", Environment: "staging"}
+	if got := config.renderDir(); got != "/tmp/app_rendered_staging" {
+		t.Errorf("renderDir() = %q", got)
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		secrets []string
+		want    string
+	}{
+		{"no secrets", "password: hunter2\n", nil, "password: hunter2\n"},
+		{"secret value", "password: hunter2\nuser: app\n", []string{"hunter2"}, "password: ******\nuser: app\n"},
+		{"every occurrence", "a: s3cr3t\nb: s3cr3t\n", []string{"s3cr3t"}, "a: ******\nb: ******\n"},
+		{"longer value first", "token: abc123\nkey: abc\n", []string{"abc", "abc123"}, "token: ******\nkey: ******\n"},
+		{"empty secret ignored", "port: 8080\n", []string{""}, "port: 8080\n"},
+		{"diff lines", "-password: old\n+password: new\n", []string{"old", "new"}, "-password: ******\n+password: ******\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactSecrets(tt.content, tt.secrets); got != tt.want {
+				t.Errorf("redactSecrets() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"temporal-aone/backend/shared"
+)
+
+// EventRollback 金丝雀回滚事件前缀：rollback.started、rollback.succeeded、rollback.failed
+const EventRollback = "rollback"
+
+// 订阅投递请求头
+const (
+	HeaderEventType = "X-Aone-Event"
+	HeaderDelivery  = "X-Aone-Delivery"
+	HeaderSignature = "X-Aone-Signature"
+)
+
+// Subscription 外部系统订阅的工作流事件，Events 支持通配符，例如 release.*、*.failed
+type Subscription struct {
+	ID     uint     `gorm:"primaryKey" json:"id"`
+	URL    string   `gorm:"size:512" json:"url"`
+	Secret string   `gorm:"size:255" json:"-"`
+	Events []string `gorm:"type:text;serializer:json" json:"events"`
+	// 只接收某个应用的事件，为空时接收全部
+	App         string    `gorm:"size:128" json:"app"`
+	Description string    `gorm:"size:512" json:"description"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Delivery 一次投递尝试的记录
+type Delivery struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	SubscriptionID uint      `gorm:"index" json:"subscription_id"`
+	DeliveryID     string    `gorm:"size:255;index" json:"delivery_id"`
+	EventType      string    `gorm:"size:64" json:"event_type"`
+	Attempt        int32     `json:"attempt"`
+	StatusCode     int       `json:"status_code"`
+	Success        bool      `json:"success"`
+	Error          string    `gorm:"type:text" json:"error,omitempty"`
+	DurationMS     int64     `json:"duration_ms"`
+	Payload        string    `gorm:"type:text" json:"payload"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Matches 判断订阅是否接收该事件
+func (s Subscription) Matches(event Event) bool {
+	if !s.Active || (s.App != "" && s.App != event.App) {
+		return false
+	}
+	for _, pattern := range s.Events {
+		if ok, _ := path.Match(pattern, event.Type); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SignPayload 返回 body 的 HMAC-SHA256 签名，格式与 GitHub 相同
+func SignPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// publishEvent 启动独立的子工作流投递事件，不等待投递完成，父工作流结束后继续重试
+func publishEvent(ctx workflow.Context, config Config, event Event) {
+	info := workflow.GetInfo(ctx)
+	childCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+		WorkflowID:        fmt.Sprintf("event-%s-%s", info.WorkflowExecution.RunID, event.Type),
+		ParentClosePolicy: enumspb.PARENT_CLOSE_POLICY_ABANDON,
+	})
+	child := workflow.ExecuteChildWorkflow(childCtx, DispatchEventWorkflow, event, config.Steps)
+	if err := child.GetChildWorkflowExecution().Get(ctx, nil); err != nil {
+		workflow.GetLogger(ctx).Warn("Failed to start DispatchEventWorkflow.", "Event", event.Type, "Error", err)
+	}
+}
+
+// DispatchEventWorkflow 向全部匹配的订阅投递事件，每个订阅由一个活动投递并独立重试
+func DispatchEventWorkflow(ctx workflow.Context, event Event, steps map[string]StepOptions) error {
+	logger := workflow.GetLogger(ctx)
+	config := Config{Steps: steps}
+
+	var ids []uint
+	err := workflow.ExecuteActivity(withStep(ctx, config, StepDispatchEvent), MatchSubscriptionsActivity, event).Get(ctx, &ids)
+	if err != nil {
+		return err
+	}
+
+	info := workflow.GetInfo(ctx)
+	futures := make([]workflow.Future, len(ids))
+	for i, id := range ids {
+		deliveryID := fmt.Sprintf("%s-%d", info.WorkflowExecution.ID, id)
+		futures[i] = workflow.ExecuteActivity(withStep(ctx, config, StepDispatchEvent), DeliverEventActivity, id, deliveryID, event)
+	}
+	for i, future := range futures {
+		if err := future.Get(ctx, nil); err != nil {
+			logger.Warn("DeliverEventActivity failed.", "Subscription", ids[i], "Error", err)
+		}
+	}
+	return nil
+}
+
+// MatchSubscriptionsActivity 返回接收该事件的订阅
+func MatchSubscriptionsActivity(ctx context.Context, event Event) ([]uint, error) {
+	var subs []Subscription
+	if err := shared.GetDB().Where("active = ?", true).Find(&subs).Error; err != nil {
+		return nil, fmt.Errorf("load subscriptions error: %v", err)
+	}
+	var ids []uint
+	for _, sub := range subs {
+		if sub.Matches(event) {
+			ids = append(ids, sub.ID)
+		}
+	}
+	return ids, nil
+}
+
+// DeliverEventActivity 向订阅地址发送签名后的事件，每次尝试都记录到投递日志
+func DeliverEventActivity(ctx context.Context, subscriptionID uint, deliveryID string, event Event) error {
+	db := shared.GetDB()
+	var sub Subscription
+	if err := db.First(&sub, subscriptionID).Error; err != nil {
+		// 订阅已被删除
+		return temporal.NewNonRetryableApplicationError(fmt.Sprintf("load subscription %d error: %v", subscriptionID, err), ErrTypeNotification, nil)
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	delivery := Delivery{
+		SubscriptionID: sub.ID,
+		DeliveryID:     deliveryID,
+		EventType:      event.Type,
+		Attempt:        1,
+		Payload:        string(body),
+	}
+	if ctx != nil && activity.IsActivity(ctx) {
+		delivery.Attempt = activity.GetInfo(ctx).Attempt
+	}
+
+	start := time.Now()
+	delivery.StatusCode, err = postSigned(ctx, sub, deliveryID, event.Type, body)
+	delivery.DurationMS = time.Since(start).Milliseconds()
+	delivery.Success = err == nil
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+	if logErr := db.Create(&delivery).Error; logErr != nil {
+		shared.Logger.Warnf("record delivery %s error: %v", deliveryID, logErr)
+	}
+	return err
+}
+
+func postSigned(ctx context.Context, sub Subscription, deliveryID, eventType string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, temporal.NewNonRetryableApplicationError(err.Error(), ErrTypeNotification, nil)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEventType, eventType)
+	req.Header.Set(HeaderDelivery, deliveryID)
+	if sub.Secret != "" {
+		req.Header.Set(HeaderSignature, SignPayload(sub.Secret, body))
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("deliver to %s error: %v", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, nil
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return resp.StatusCode, fmt.Errorf("deliver to %s: status %d: %s", req.URL.Host, resp.StatusCode, strings.TrimSpace(string(respBody)))
+}
@@ -0,0 +1,56 @@
+package pkg
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSubscriptionMatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		sub   Subscription
+		event Event
+		want  bool
+	}{
+		{"exact", Subscription{Active: true, Events: []string{"build.succeeded"}}, Event{Type: "build.succeeded"}, true},
+		{"prefix wildcard", Subscription{Active: true, Events: []string{"release.*"}}, Event{Type: "release.failed"}, true},
+		{"suffix wildcard", Subscription{Active: true, Events: []string{"*.failed"}}, Event{Type: "rollback.failed"}, true},
+		{"all", Subscription{Active: true, Events: []string{"*"}}, Event{Type: "rollback.started"}, true},
+		{"other event", Subscription{Active: true, Events: []string{"release.*"}}, Event{Type: "build.failed"}, false},
+		{"inactive", Subscription{Events: []string{"*"}}, Event{Type: "build.failed"}, false},
+		{"app filter", Subscription{Active: true, App: "demo", Events: []string{"*"}}, Event{Type: "build.failed", App: "other"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sub.Matches(tt.event); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostSigned(t *testing.T) {
+	body := []byte(`{"type":"build.succeeded"}`)
+	var signature, delivery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(HeaderSignature)
+		delivery = r.Header.Get(HeaderDelivery)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sub := Subscription{URL: server.URL, Secret: "s3cret"}
+	status, err := postSigned(context.Background(), sub, "event-1", "build.succeeded", body)
+	if err != nil || status != http.StatusAccepted {
+		t.Fatalf("postSigned() = %d, %v", status, err)
+	}
+	if signature != SignPayload("s3cret", body) {
+		t.Errorf("signature = %q", signature)
+	}
+	if delivery != "event-1" {
+		t.Errorf("delivery = %q", delivery)
+	}
+}
@@ -0,0 +1,188 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// DeployModeSystemd 应用以 systemd 单元运行，由 systemd 负责崩溃和重启后的拉起
+const DeployModeSystemd = "systemd"
+
+// SystemdSpec systemd 单元配置，零值字段使用默认值
+type SystemdSpec struct {
+	UnitName         string
+	Description      string
+	ExecStart        string
+	WorkingDirectory string
+	User             string
+	Environment      map[string]string
+	Restart          string
+	UnitDir          string
+	// 发布报告中附带的日志行数
+	JournalLines int
+	UseSudo      bool
+}
+
+// SystemdStatus 单元状态和最近的日志
+type SystemdStatus struct {
+	Unit        string
+	ActiveState string
+	SubState    string
+	MainPID     string
+	Journal     []string
+}
+
+func (s SystemdSpec) withDefaults(config Config) SystemdSpec {
+	binary := "app"
+	if config.BinaryPath != "" {
+		binary = filepath.Base(config.BinaryPath)
+	}
+	base := config.ECSUploadPath
+	if config.Releases.Enabled {
+		base = config.Releases.withDefaults(config).currentLink()
+	}
+	if base == "" {
+		base = "/opt/" + binary
+	}
+	if s.UnitName == "" {
+		s.UnitName = binary + ".service"
+	}
+	if !strings.HasSuffix(s.UnitName, ".service") {
+		s.UnitName += ".service"
+	}
+	if s.Description == "" {
+		s.Description = binary
+	}
+	if s.WorkingDirectory == "" {
+		s.WorkingDirectory = base
+	}
+	if s.ExecStart == "" {
+		s.ExecStart = path.Join(base, binary)
+	}
+	if s.Restart == "" {
+		s.Restart = "on-failure"
+	}
+	if s.UnitDir == "" {
+		s.UnitDir = "/etc/systemd/system"
+	}
+	if s.JournalLines <= 0 {
+		s.JournalLines = 50
+	}
+	return s
+}
+
+// renderUnit 生成单元文件内容
+func (s SystemdSpec) renderUnit() string {
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	fmt.Fprintf(&b, "Description=%s\n", s.Description)
+	b.WriteString("After=network-online.target\nWants=network-online.target\n\n")
+
+	b.WriteString("[Service]\n")
+	b.WriteString("Type=simple\n")
+	fmt.Fprintf(&b, "WorkingDirectory=%s\n", s.WorkingDirectory)
+	fmt.Fprintf(&b, "ExecStart=%s\n", s.ExecStart)
+	if s.User != "" {
+		fmt.Fprintf(&b, "User=%s\n", s.User)
+	}
+	keys := make([]string, 0, len(s.Environment))
+	for key := range s.Environment {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, "Environment=%q\n", key+"="+s.Environment[key])
+	}
+	fmt.Fprintf(&b, "Restart=%s\n", s.Restart)
+	b.WriteString("RestartSec=5\n\n")
+
+	b.WriteString("[Install]\n")
+	b.WriteString("WantedBy=multi-user.target\n")
+	return b.String()
+}
+
+func (s SystemdSpec) sudo(command string) string {
+	if s.UseSudo {
+		return "sudo " + command
+	}
+	return command
+}
+
+// parseSystemctlShow 解析 systemctl show 输出的 key=value 行
+func parseSystemctlShow(output string) map[string]string {
+	props := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			props[key] = value
+		}
+	}
+	return props
+}
+
+// SystemdDeployActivity 安装单元文件并重启服务
+func SystemdDeployActivity(ctx context.Context, config Config) error {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+
+	spec := config.Systemd.withDefaults(config)
+	unitPath := path.Join(spec.UnitDir, spec.UnitName)
+	tmp := path.Join("/tmp", spec.UnitName)
+
+	logs.Printf("Installing %s on %s", unitPath, config.ECSServer)
+	reportProgress(ctx, "installing %s", spec.UnitName)
+	script := strings.Join([]string{
+		fmt.Sprintf("printf '%%s' %s > %s", shellQuote(spec.renderUnit()), shellQuote(tmp)),
+		spec.sudo(fmt.Sprintf("mv %s %s", shellQuote(tmp), shellQuote(unitPath))),
+		spec.sudo("systemctl daemon-reload"),
+		spec.sudo("systemctl enable " + shellQuote(spec.UnitName)),
+		spec.sudo("systemctl restart " + shellQuote(spec.UnitName)),
+	}, " && ")
+	if _, err := runRemote(ctx, config, logs, script); err != nil {
+		return err
+	}
+
+	logs.Printf("Restarted %s", spec.UnitName)
+	return nil
+}
+
+// SystemdStatusActivity 读取单元状态和最近日志，用于发布报告
+func SystemdStatusActivity(ctx context.Context, config Config) (SystemdStatus, error) {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+
+	spec := config.Systemd.withDefaults(config)
+	unit := shellQuote(spec.UnitName)
+	status := SystemdStatus{Unit: spec.UnitName}
+
+	output, err := runRemote(ctx, config, logs, "systemctl show -p ActiveState -p SubState -p MainPID "+unit)
+	if err != nil {
+		return status, err
+	}
+	props := parseSystemctlShow(output)
+	status.ActiveState = props["ActiveState"]
+	status.SubState = props["SubState"]
+	status.MainPID = props["MainPID"]
+
+	output, err = runRemote(ctx, config, logs, spec.sudo(fmt.Sprintf("journalctl -u %s -n %d --no-pager -o short-iso", unit, spec.JournalLines)))
+	if err != nil {
+		return status, err
+	}
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		if line != "" {
+			status.Journal = append(status.Journal, line)
+		}
+	}
+
+	logs.Printf("%s is %s (%s), main pid %s", spec.UnitName, status.ActiveState, status.SubState, status.MainPID)
+	if status.ActiveState != "active" {
+		return status, temporal.NewApplicationError(
+			fmt.Sprintf("unit %s is %s (%s)", spec.UnitName, status.ActiveState, status.SubState), ErrTypeUnitInactive, status)
+	}
+	return status, nil
+}
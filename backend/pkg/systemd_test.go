@@ -0,0 +1,42 @@
+package pkg
+
+import "testing"
+
+func TestSystemdRenderUnit(t *testing.T) {
+	spec := SystemdSpec{
+		User:        "app",
+		Environment: map[string]string{"PORT": "8080", "APP_ENV": "prod"},
+	}.withDefaults(Config{BinaryPath: "build/myapp", ECSUploadPath: "/srv/myapp"})
+
+	if spec.UnitName != "myapp.service" {
+		t.Errorf("UnitName = %s, want myapp.service", spec.UnitName)
+	}
+	want := `[Unit]
+Description=myapp
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+WorkingDirectory=/srv/myapp
+ExecStart=/srv/myapp/myapp
+User=app
+Environment="APP_ENV=prod"
+Environment="PORT=8080"
+Restart=on-failure
+RestartSec=5
+
+[Install]
+WantedBy=multi-user.target
+`
+	if got := spec.renderUnit(); got != want {
+		t.Errorf("renderUnit() = %s, want %s", got, want)
+	}
+}
+
+func TestParseSystemctlShow(t *testing.T) {
+	props := parseSystemctlShow("ActiveState=active\nSubState=running\nMainPID=4242\n")
+	if props["ActiveState"] != "active" || props["SubState"] != "running" || props["MainPID"] != "4242" {
+		t.Errorf("parseSystemctlShow() = %v", props)
+	}
+}
@@ -0,0 +1,341 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitConfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	gitHttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+	"gorm.io/gorm"
+
+	"temporal-aone/backend/shared"
+)
+
+// 版本号生成策略
+const (
+	VersionTimestamp = "timestamp"
+	VersionSemver    = "semver"
+	VersionExplicit  = "explicit"
+)
+
+// ErrTypeVersion 版本策略配置错误或标签冲突，重试没有意义
+const ErrTypeVersion = "VersionError"
+
+// semver 没有历史标签时的默认版本
+const defaultInitialVersion = "0.1.0"
+
+// VersioningSpec 版本号生成方式，Strategy 为空时有 Config.Version 则直接使用，否则使用应用的配置，
+// 应用也没有配置时使用时间戳
+type VersioningSpec struct {
+	Strategy string
+	// 版本号和标签的前缀，例如 v
+	Prefix string
+	// semver 没有历史标签时使用的版本
+	Initial string
+	// 构建成功后以版本号推送附注标签
+	Tag bool
+}
+
+// VersionResult 生成的版本号以及最终生效的版本策略
+type VersionResult struct {
+	Version string
+	// semver 策略下作为基准的上一个标签
+	Previous string
+	Spec     VersioningSpec
+}
+
+// semver 只比较 MAJOR.MINOR.PATCH，带预发布后缀的标签不参与计算
+type semver struct {
+	Major, Minor, Patch int
+}
+
+func parseSemver(s string) (semver, bool) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return semver{}, false
+	}
+	var nums [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return semver{}, false
+		}
+		nums[i] = n
+	}
+	return semver{nums[0], nums[1], nums[2]}, true
+}
+
+func (v semver) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+func (v semver) less(o semver) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	return v.Patch < o.Patch
+}
+
+// bump 按约定式提交递增版本：破坏性变更升主版本，feat 升次版本，其它提交（包括只有合并提交）升修订号
+func (v semver) bump(entries []ChangelogEntry) semver {
+	feature := false
+	for _, entry := range entries {
+		if entry.Breaking {
+			return semver{Major: v.Major + 1}
+		}
+		if entry.Type == "feat" {
+			feature = true
+		}
+	}
+	if feature {
+		return semver{Major: v.Major, Minor: v.Minor + 1}
+	}
+	return semver{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+}
+
+// latestSemverTag 返回带指定前缀的最大版本标签及其指向的提交
+func latestSemverTag(repo *git.Repository, prefix string) (name string, version semver, commit plumbing.Hash, err error) {
+	tags, err := repo.Tags()
+	if err != nil {
+		return "", semver{}, plumbing.ZeroHash, err
+	}
+	found := false
+	err = tags.ForEach(func(ref *plumbing.Reference) error {
+		tagName := ref.Name().Short()
+		if !strings.HasPrefix(tagName, prefix) {
+			return nil
+		}
+		v, ok := parseSemver(strings.TrimPrefix(tagName, prefix))
+		if !ok || (found && !version.less(v)) {
+			return nil
+		}
+		hash := ref.Hash()
+		// 附注标签指向标签对象
+		if tag, err := repo.TagObject(hash); err == nil {
+			hash = tag.Target
+		}
+		name, version, commit, found = tagName, v, hash, true
+		return nil
+	})
+	return name, version, commit, err
+}
+
+// resolveHead 返回 Config.Commit，未指定时返回 HEAD
+func resolveHead(repo *git.Repository, config Config) (string, error) {
+	if config.Commit != "" {
+		return config.Commit, nil
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("resolve HEAD error: %v", err)
+	}
+	return head.Hash().String(), nil
+}
+
+// nextSemver 从最近的版本标签开始，按之后的提交信息计算下一个版本
+func nextSemver(repo *git.Repository, head string, spec VersioningSpec) (VersionResult, error) {
+	result := VersionResult{Spec: spec}
+	previous, current, commit, err := latestSemverTag(repo, spec.Prefix)
+	if err != nil {
+		return result, fmt.Errorf("list tags error: %v", err)
+	}
+	if previous == "" {
+		initial := spec.Initial
+		if initial == "" {
+			initial = defaultInitialVersion
+		}
+		v, ok := parseSemver(strings.TrimPrefix(initial, spec.Prefix))
+		if !ok {
+			return result, temporal.NewNonRetryableApplicationError(fmt.Sprintf("invalid initial version %q", initial), ErrTypeVersion, nil)
+		}
+		result.Version = spec.Prefix + v.String()
+		return result, nil
+	}
+
+	// 没有新提交时再次构建会复用已有版本，覆盖已发布的版本目录和记录
+	if hash, err := repo.ResolveRevision(plumbing.Revision(head)); err == nil && *hash == commit {
+		return result, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("no commits since %s, pass an explicit version to rebuild it", previous), ErrTypeVersion, nil)
+	}
+	changes, err := buildChangelog(repo, commit.String(), head)
+	if err != nil {
+		return result, fmt.Errorf("walk history error: %v", err)
+	}
+	result.Previous = previous
+	result.Version = spec.Prefix + current.bump(changes.Entries).String()
+	return result, nil
+}
+
+// versioningSpec 返回最终生效的版本策略
+func versioningSpec(db *gorm.DB, config Config) (VersioningSpec, error) {
+	spec := config.Versioning
+	if spec.Strategy != "" {
+		return spec, nil
+	}
+	if config.Version != "" {
+		spec.Strategy = VersionExplicit
+		return spec, nil
+	}
+	var app Application
+	err := db.Where("name = ?", config.AppName()).First(&app).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return spec, fmt.Errorf("load application error: %v", err)
+	}
+	if app.VersionStrategy != "" {
+		return app.Versioning(), nil
+	}
+	spec.Strategy = VersionTimestamp
+	return spec, nil
+}
+
+// VersionActivity 按版本策略生成本次构建的版本号，结果记录在工作流历史中，重放时不会变化
+func VersionActivity(ctx context.Context, config Config) (VersionResult, error) {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+
+	spec, err := versioningSpec(shared.GetDB(), config)
+	if err != nil {
+		return VersionResult{}, err
+	}
+	result := VersionResult{Spec: spec}
+
+	switch spec.Strategy {
+	case VersionExplicit:
+		if config.Version == "" {
+			return result, temporal.NewNonRetryableApplicationError("explicit versioning needs a version", ErrTypeVersion, nil)
+		}
+		result.Version = config.Version
+	case VersionTimestamp:
+		result.Version = spec.Prefix + time.Now().Format("20060102150405")
+	case VersionSemver:
+		repo, err := git.PlainOpen(config.LocalPath)
+		if err != nil {
+			return result, temporal.NewNonRetryableApplicationError(fmt.Sprintf("semver versioning needs a git repository at %q: %v", config.LocalPath, err), ErrTypeVersion, nil)
+		}
+		head, err := resolveHead(repo, config)
+		if err != nil {
+			return result, err
+		}
+		if result, err = nextSemver(repo, head, spec); err != nil {
+			return result, err
+		}
+	default:
+		return result, temporal.NewNonRetryableApplicationError(fmt.Sprintf("unsupported versioning strategy %q", spec.Strategy), ErrTypeVersion, nil)
+	}
+
+	if err := validateReleaseVersion(result.Version); err != nil {
+		return result, temporal.NewNonRetryableApplicationError(err.Error(), ErrTypeVersion, nil)
+	}
+	logs.Printf("Version %s (%s strategy, previous %q)", result.Version, spec.Strategy, result.Previous)
+	return result, nil
+}
+
+// createVersionTag 在 commit 上创建附注标签；标签已指向同一提交时视为成功，便于活动重试
+func createVersionTag(repo *git.Repository, name, commit, message string) (bool, error) {
+	hash := plumbing.NewHash(commit)
+	if ref, err := repo.Tag(name); err == nil {
+		target := ref.Hash()
+		if tag, err := repo.TagObject(target); err == nil {
+			target = tag.Target
+		}
+		if target != hash {
+			return false, temporal.NewNonRetryableApplicationError(fmt.Sprintf("tag %s already points to %s", name, target), ErrTypeVersion, nil)
+		}
+		return false, nil
+	}
+	_, err := repo.CreateTag(name, hash, &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "temporal-aone", Email: "temporal-aone@localhost", When: time.Now()},
+		Message: message,
+	})
+	return err == nil, err
+}
+
+// TagVersionActivity 以版本号创建附注标签并推送到 origin
+func TagVersionActivity(ctx context.Context, config Config) error {
+	logs := openActivityLog(ctx)
+	defer logs.Close()
+
+	repo, err := git.PlainOpen(config.LocalPath)
+	if err != nil {
+		return temporal.NewNonRetryableApplicationError(fmt.Sprintf("tagging needs a git repository at %q: %v", config.LocalPath, err), ErrTypeVersion, nil)
+	}
+	head, err := resolveHead(repo, config)
+	if err != nil {
+		return err
+	}
+	message := fmt.Sprintf("%s %s", config.AppName(), config.Version)
+	if config.Changelog != "" {
+		message += "\n\n" + config.Changelog
+	}
+	created, err := createVersionTag(repo, config.Version, head, message)
+	if err != nil {
+		return err
+	}
+	if created {
+		logs.Printf("Created tag %s at %s", config.Version, head)
+	}
+
+	reportProgress(ctx, "pushing tag %s", config.Version)
+	refSpec := gitConfig.RefSpec(fmt.Sprintf("refs/tags/%s:refs/tags/%s", config.Version, config.Version))
+	err = repo.PushContext(ctx, &git.PushOptions{
+		RemoteName: "origin",
+		RefSpecs:   []gitConfig.RefSpec{refSpec},
+		Auth: &gitHttp.BasicAuth{
+			Username: config.UserName,
+			Password: config.Token,
+		},
+		Progress: logs,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("push tag %s error: %v", config.Version, err)
+	}
+	logs.Printf("Pushed tag %s", config.Version)
+	return nil
+}
+
+// resolveVersion 在工作流中通过活动确定版本号，返回带版本号和生效策略的配置
+func resolveVersion(ctx workflow.Context, config Config, progress *progressTracker) (Config, error) {
+	logger := workflow.GetLogger(ctx)
+	progress.addSteps(1)
+	progress.enter(StepVersion)
+
+	var result VersionResult
+	err := workflow.ExecuteActivity(withStep(ctx, config, StepVersion), VersionActivity, config).Get(ctx, &result)
+	if err != nil {
+		logger.Error("VersionActivity failed.", "Error", err)
+		progress.fail(err)
+		return config, err
+	}
+	config.Version = result.Version
+	config.Versioning = result.Spec
+	progress.logf("version %s (%s)", result.Version, result.Spec.Strategy)
+	return config, nil
+}
+
+// tagVersion 构建成功后推送版本标签
+func tagVersion(ctx workflow.Context, config Config, progress *progressTracker) error {
+	logger := workflow.GetLogger(ctx)
+	progress.addSteps(1)
+	progress.enter(StepTag)
+
+	err := workflow.ExecuteActivity(withStep(ctx, config, StepTag), TagVersionActivity, config).Get(ctx, nil)
+	if err != nil {
+		logger.Error("TagVersionActivity failed.", "Error", err)
+		progress.fail(err)
+		return err
+	}
+	progress.logf("tagged %s", config.Version)
+	return nil
+}
@@ -0,0 +1,129 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestSemverBump(t *testing.T) {
+	base := semver{1, 4, 2}
+	tests := []struct {
+		name    string
+		entries []ChangelogEntry
+		want    string
+	}{
+		{"only merges", nil, "1.4.3"},
+		{"fix", []ChangelogEntry{{Type: "fix"}, {Type: "chore"}}, "1.4.3"},
+		{"feature", []ChangelogEntry{{Type: "fix"}, {Type: "feat"}}, "1.5.0"},
+		{"breaking", []ChangelogEntry{{Type: "feat"}, {Type: "refactor", Breaking: true}}, "2.0.0"},
+		{"other", []ChangelogEntry{{Type: "other"}}, "1.4.3"},
+	}
+
+	for _, tt := range tests {
+		if got := base.bump(tt.entries).String(); got != tt.want {
+			t.Errorf("%s: bump() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseSemver(t *testing.T) {
+	tests := []struct {
+		in   string
+		want semver
+		ok   bool
+	}{
+		{"1.2.3", semver{1, 2, 3}, true},
+		{"0.10.0", semver{0, 10, 0}, true},
+		{"1.2", semver{}, false},
+		{"1.2.3-rc.1", semver{}, false},
+		{"v1.2.3", semver{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseSemver(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseSemver(%q) = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNextSemver(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := func(message string) string {
+		if err := os.WriteFile(filepath.Join(dir, "README"), []byte(message), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := worktree.Add("README"); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := worktree.Commit(message, &git.CommitOptions{
+			Author: &object.Signature{Name: "alice", Email: "alice@example.com", When: time.Now()},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash.String()
+	}
+
+	spec := VersioningSpec{Strategy: VersionSemver, Prefix: "v"}
+	first := commit("chore: initial import")
+	result, err := nextSemver(repo, first, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Version != "v0.1.0" || result.Previous != "" {
+		t.Errorf("without tags got %q from %q, want v0.1.0", result.Version, result.Previous)
+	}
+
+	if _, err := createVersionTag(repo, "v0.1.0", first, "v0.1.0"); err != nil {
+		t.Fatal(err)
+	}
+	// 没有前缀的标签和更小的标签不影响结果
+	if _, err := repo.CreateTag("0.9.0", plumbing.NewHash(first), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateTag("v0.0.9", plumbing.NewHash(first), nil); err != nil {
+		t.Fatal(err)
+	}
+	commit("fix: handle empty hosts")
+	head := commit("feat(api): add promote endpoint")
+
+	result, err = nextSemver(repo, head, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Version != "v0.2.0" || result.Previous != "v0.1.0" {
+		t.Errorf("got %q from %q, want v0.2.0 from v0.1.0", result.Version, result.Previous)
+	}
+
+	// 重试时标签已指向同一提交，指向其它提交时报错
+	created, err := createVersionTag(repo, "v0.2.0", head, "v0.2.0")
+	if err != nil || !created {
+		t.Fatalf("createVersionTag() = %v, %v", created, err)
+	}
+	if created, err = createVersionTag(repo, "v0.2.0", head, "v0.2.0"); err != nil || created {
+		t.Errorf("retry createVersionTag() = %v, %v, want false, nil", created, err)
+	}
+	if _, err = createVersionTag(repo, "v0.2.0", first, "v0.2.0"); err == nil {
+		t.Errorf("createVersionTag() on another commit should fail")
+	}
+
+	// 标签之后没有新提交时不复用已有版本
+	if result, err = nextSemver(repo, head, spec); err == nil {
+		t.Errorf("nextSemver() without new commits = %q, want error", result.Version)
+	}
+}
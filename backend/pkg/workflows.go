@@ -1,101 +1,782 @@
 package pkg
 
 import (
-	"time"
+	"errors"
+	"fmt"
+	"strings"
 
+	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
 
-func generateVersion() string {
-	return time.Now().Format("20060102150405")
-}
-
 func ConfigWorkflow(ctx workflow.Context, config Config) error {
-	ao := workflow.ActivityOptions{
-		StartToCloseTimeout: time.Minute,
-	}
-	ctx = workflow.WithActivityOptions(ctx, ao)
 	logger := workflow.GetLogger(ctx)
-	config.Version = generateVersion()
+
+	// 只克隆仓库，不生成版本号：semver 策略需要已克隆的仓库，而克隆结果不会用到版本号
+	progress, err := newProgressTracker(ctx, 1)
+	if err != nil {
+		return err
+	}
 
 	// 执行ConfigRepoActivity
-	err := workflow.ExecuteActivity(ctx, ConfigRepoActivity, config).Get(ctx, &config)
+	progress.enter(StepConfigRepo)
+	err = workflow.ExecuteActivity(withStep(ctx, config, StepConfigRepo), ConfigRepoActivity, config).Get(ctx, &config)
 	if err != nil {
 		logger.Error("ConfigRepoActivity failed.", "Error", err)
+		progress.fail(err)
 		return err
 	}
 
-	logger.Info("Config workflow completed successfully", "Version", config.Version)
+	logger.Info("Config workflow completed successfully", "Repo", config.RepoURL)
+	progress.complete()
 	return nil
 }
 
-func BuildUploadWorkflow(ctx workflow.Context, config Config) error {
-	ao := workflow.ActivityOptions{
-		StartToCloseTimeout: time.Minute,
-	}
-	ctx = workflow.WithActivityOptions(ctx, ao)
+func BuildUploadWorkflow(ctx workflow.Context, config Config) (err error) {
+	defer notifyOutcome(ctx, &config, EventBuild, workflow.Now(ctx), &err)
 	logger := workflow.GetLogger(ctx)
 
+	steps := 4
+	if config.Environment != "" {
+		steps++
+	}
+	if config.Commit != "" {
+		steps++
+	}
+	// 变更日志
+	steps++
+	progress, err := newProgressTracker(ctx, steps)
+	if err != nil {
+		return err
+	}
+
+	// 指定提交时先检出代码，例如由 webhook 触发的构建
+	if config.Commit != "" {
+		progress.enter(StepCheckout)
+		err = workflow.ExecuteActivity(withStep(ctx, config, StepCheckout), CheckoutActivity, config).Get(ctx, &config.LocalPath)
+		if err != nil {
+			logger.Error("CheckoutActivity failed.", "Error", err)
+			progress.fail(err)
+			return err
+		}
+		progress.logf("checked out %s into %s", config.Commit, config.LocalPath)
+	}
+
+	// 按版本策略生成版本号，变更日志和产物都以它为准
+	if config, err = resolveVersion(ctx, config, progress); err != nil {
+		return err
+	}
+
+	// 生成自上一次成功发布以来的变更日志
+	progress.enter(StepChangelog)
+	var changelog Changelog
+	err = workflow.ExecuteActivity(withStep(ctx, config, StepChangelog), ChangelogActivity, config).Get(ctx, &changelog)
+	if err != nil {
+		logger.Error("ChangelogActivity failed.", "Error", err)
+		progress.fail(err)
+		return err
+	}
+	config.Changelog = changelog.Markdown
+	if config.Commit == "" {
+		config.Commit = changelog.ToCommit
+	}
+	progress.logf("%d commits since %s", len(changelog.Entries), shortCommit(changelog.FromCommit))
+
 	// 执行BuildActivity
-	err := workflow.ExecuteActivity(ctx, BuildActivity, config).Get(ctx, nil)
+	progress.enter(StepBuild)
+	err = workflow.ExecuteActivity(withStep(ctx, config, StepBuild), BuildActivity, config).Get(ctx, nil)
 	if err != nil {
 		logger.Error("BuildActivity failed.", "Error", err)
+		progress.fail(err)
 		return err
 	}
 
 	// 执行TestActivity
-	err = workflow.ExecuteActivity(ctx, TestActivity, config).Get(ctx, nil)
+	progress.enter(StepTest)
+	err = workflow.ExecuteActivity(withStep(ctx, config, StepTest), TestActivity, config).Get(ctx, nil)
 	if err != nil {
 		logger.Error("TestActivity failed.", "Error", err)
+		progress.fail(err)
 		return err
 	}
 
+	if err := packageAndUpload(ctx, config, progress); err != nil {
+		return err
+	}
+
+	if config.Versioning.Tag {
+		if err := tagVersion(ctx, config, progress); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("Build and upload workflow completed successfully", "Version", config.Version)
+	progress.complete()
+	return nil
+}
+
+// packageAndUpload 按环境渲染配置后打包，并上传到发布主机
+func packageAndUpload(ctx workflow.Context, config Config, progress *progressTracker) error {
+	logger := workflow.GetLogger(ctx)
+
+	// 按环境渲染配置模板，渲染结果和差异记录到数据库
+	if config.Environment != "" {
+		progress.enter(StepRenderConfig)
+		var rendered RenderedConfig
+		err := workflow.ExecuteActivity(withStep(ctx, config, StepRenderConfig), RenderConfigActivity, config).Get(ctx, &rendered)
+		if err != nil {
+			logger.Error("RenderConfigActivity failed.", "Error", err)
+			progress.fail(err)
+			return err
+		}
+		if rendered.Changed {
+			progress.logf("rendered config for %s changed (record %d, sha256 %s)", config.Environment, rendered.ID, rendered.Checksum)
+		} else {
+			progress.logf("rendered config for %s unchanged", config.Environment)
+		}
+	}
+
 	// 执行PackageActivity
-	err = workflow.ExecuteActivity(ctx, PackageActivity, config).Get(ctx, nil)
+	progress.enter(StepPackage)
+	var artifact Artifact
+	err := workflow.ExecuteActivity(withStep(ctx, config, StepPackage), PackageActivity, config).Get(ctx, &artifact)
 	if err != nil {
 		logger.Error("PackageActivity failed.", "Error", err)
+		progress.fail(err)
 		return err
 	}
+	progress.logf("binary sha256 %s, config sha256 %s", artifact.BinaryChecksum, artifact.ConfigChecksum)
 
-	// 执行UploadToECSActivity
-	err = workflow.ExecuteActivity(ctx, UploadToECSActivity, config).Get(ctx, nil)
+	// 执行UploadToECSActivity，启用版本目录时上传到各主机的 releases/<version>/
+	progress.enter(StepUpload)
+	if config.Releases.Enabled {
+		for _, host := range config.releaseHosts() {
+			err = workflow.ExecuteActivity(withStep(ctx, config, StepUpload), StageReleaseActivity, config.forHost(host)).Get(ctx, nil)
+			if err != nil {
+				logger.Error("StageReleaseActivity failed.", "Host", host, "Error", err)
+				progress.fail(err)
+				return err
+			}
+			progress.logf("staged %s on %s", config.Version, host)
+		}
+		return nil
+	}
+	err = workflow.ExecuteActivity(withStep(ctx, config, StepUpload), UploadToECSActivity, config).Get(ctx, nil)
 	if err != nil {
 		logger.Error("UploadToECSActivity failed.", "Error", err)
+		progress.fail(err)
+	}
+	return err
+}
+
+// PromoteWorkflow 将已构建的版本晋级到下一个环境：从产物仓库取回上一个环境发布的二进制和配置模板，
+// 按目标环境渲染配置后打包上传，再以子工作流发布
+func PromoteWorkflow(ctx workflow.Context, config Config) (err error) {
+	defer notifyOutcome(ctx, &config, EventPromote, workflow.Now(ctx), &err)
+	logger := workflow.GetLogger(ctx)
+
+	// 取回产物、打包、上传、发布
+	steps := 4
+	if config.Environment != "" {
+		steps++
+	}
+	progress, err := newProgressTracker(ctx, steps)
+	if err != nil {
+		return err
+	}
+
+	progress.enter(StepStageArtifact)
+	err = workflow.ExecuteActivity(withStep(ctx, config, StepStageArtifact), StageArtifactActivity, config).Get(ctx, nil)
+	if err != nil {
+		logger.Error("StageArtifactActivity failed.", "Error", err)
+		progress.fail(err)
+		return err
+	}
+	progress.logf("restored binary sha256 %s from the artifact store", config.BinaryChecksum)
+
+	if err := packageAndUpload(ctx, config, progress); err != nil {
+		return err
+	}
+
+	progress.enter(StepPromote)
+	childCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+		WorkflowID: workflow.GetInfo(ctx).WorkflowExecution.ID + "-release",
+	})
+	err = workflow.ExecuteChildWorkflow(childCtx, ReleaseWorkflow, config).Get(ctx, nil)
+	if err != nil {
+		logger.Error("ReleaseWorkflow failed.", "Environment", config.Environment, "Error", err)
+		progress.fail(err)
+		return err
+	}
+
+	logger.Info("Promote workflow completed successfully", "Version", config.Version, "Environment", config.Environment)
+	progress.complete()
+	return nil
+}
+
+// ConfigReleaseWorkflow 不重新构建，仅发布仓库中指定版本的配置文件并让应用重新加载
+func ConfigReleaseWorkflow(ctx workflow.Context, config Config) (err error) {
+	defer notifyOutcome(ctx, &config, EventConfigRelease, workflow.Now(ctx), &err)
+	logger := workflow.GetLogger(ctx)
+	hosts := config.releaseHosts()
+	spec := config.ConfigRelease.withDefaults(config)
+
+	reloadSteps := 2
+	if spec.ReloadMode == ReloadRestart {
+		reloadSteps = stepsPerHost(config)
+	}
+	progress, err := newProgressTracker(ctx, 3+len(hosts)*(1+reloadSteps))
+	if err != nil {
+		return err
+	}
+	// 配置发布同样受发布窗口和封版期约束
+	if config.Environment != "" {
+		if err := enforcePolicy(ctx, config, progress); err != nil {
+			return err
+		}
+	}
+
+	progress.enter(StepFetchConfig)
+	var file ConfigFile
+	err = workflow.ExecuteActivity(withStep(ctx, config, StepFetchConfig), FetchConfigActivity, config).Get(ctx, &file)
+	if err != nil {
+		logger.Error("FetchConfigActivity failed.", "Error", err)
+		progress.fail(err)
+		return err
+	}
+	progress.logf("fetched %s at %s (%s)", file.Path, file.Ref, file.Commit)
+
+	progress.enter(StepValidateConfig)
+	err = workflow.ExecuteActivity(withStep(ctx, config, StepValidateConfig), ValidateConfigActivity, file).Get(ctx, nil)
+	if err != nil {
+		logger.Error("ValidateConfigActivity failed.", "Error", err)
+		progress.fail(err)
+		return err
+	}
+
+	progress.enter(StepDiffConfig)
+	var changed []string
+	for _, host := range hosts {
+		var diff ConfigDiff
+		err = workflow.ExecuteActivity(withStep(ctx, config, StepDiffConfig), DiffConfigActivity, config.forHost(host), file).Get(ctx, &diff)
+		if err != nil {
+			logger.Error("DiffConfigActivity failed.", "Host", host, "Error", err)
+			progress.fail(err)
+			return err
+		}
+		if !diff.Changed {
+			progress.logf("%s: config unchanged", host)
+			continue
+		}
+		progress.logf("%s: config changed\n%s", host, diff.Diff)
+		changed = append(changed, host)
+	}
+	if len(changed) == 0 {
+		logger.Info("Config unchanged on all hosts, nothing to release")
+		progress.complete()
+		return nil
+	}
+
+	for _, host := range changed {
+		if err := releaseConfigHost(ctx, config.forHost(host), spec, file, progress); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("Config release workflow completed successfully", "Commit", file.Commit)
+	progress.complete()
+	return nil
+}
+
+// releaseConfigHost 写入配置并重新加载，健康检查失败时恢复旧配置
+func releaseConfigHost(ctx workflow.Context, config Config, spec ConfigReleaseSpec, file ConfigFile, progress *progressTracker) error {
+	logger := workflow.GetLogger(ctx)
+
+	progress.enter(StepDistributeConfig)
+	err := workflow.ExecuteActivity(withStep(ctx, config, StepDistributeConfig), DistributeConfigActivity, config, file).Get(ctx, nil)
+	if err != nil {
+		logger.Error("DistributeConfigActivity failed.", "Host", config.ECSServer, "Error", err)
+		progress.fail(err)
 		return err
 	}
 
-	logger.Info("Build and upload workflow completed successfully")
+	err = reloadConfig(ctx, config, spec, progress)
+	if err == nil {
+		return nil
+	}
+
+	logger.Error("Config reload failed, restoring previous config.", "Host", config.ECSServer, "Error", err)
+	var restored bool
+	restoreErr := workflow.ExecuteActivity(withStep(ctx, config, StepDistributeConfig), RestoreConfigActivity, config).Get(ctx, &restored)
+	if restoreErr == nil && restored {
+		restoreErr = reloadConfig(ctx, config, spec, progress)
+	}
+	switch {
+	case restoreErr != nil:
+		err = fmt.Errorf("%v; restoring previous config failed: %v", err, restoreErr)
+	case restored:
+		progress.logf("%s: previous config restored", config.ECSServer)
+	default:
+		// 首次发布没有旧配置可以恢复，只删除新文件
+		progress.logf("%s: no previous config to restore, removed the new config", config.ECSServer)
+	}
+	progress.fail(err)
+	return err
+}
+
+// reloadConfig 按配置的方式让应用加载新配置并做健康检查
+func reloadConfig(ctx workflow.Context, config Config, spec ConfigReleaseSpec, progress *progressTracker) error {
+	if spec.ReloadMode == ReloadRestart {
+		return restartHost(ctx, config, progress)
+	}
+
+	progress.enter(StepReload)
+	err := workflow.ExecuteActivity(withStep(ctx, config, StepReload), ReloadConfigActivity, config).Get(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	progress.enter(StepHealthCheck)
+	var health HealthCheckResult
+	err = workflow.ExecuteActivity(withStep(ctx, config, StepHealthCheck), HealthCheckActivity, config).Get(ctx, &health)
+	if err != nil {
+		return err
+	}
+	progress.logf("%s healthy after %d probes", config.ECSServer, health.Attempts)
+	return nil
+}
+
+func ReleaseWorkflow(ctx workflow.Context, config Config) (err error) {
+	defer notifyOutcome(ctx, &config, EventRelease, workflow.Now(ctx), &err)
+	progress, err := newProgressTracker(ctx, 0)
+	if err != nil {
+		return err
+	}
+	// 指定环境时先检查发布窗口和封版期
+	if config.Environment != "" {
+		if err := enforcePolicy(ctx, config, progress); err != nil {
+			return err
+		}
+	}
+
+	// 记录发布结果，作为主机清单和晋级到下一个环境的依据
+	release := newRelease(ctx, config)
+	recordCtx := withStep(ctx, config, StepRecordRelease)
+	if err := workflow.ExecuteActivity(recordCtx, RecordReleaseActivity, release).Get(ctx, &release); err != nil {
+		return err
+	}
+	config.Changelog = release.Changelog
+
+	err = deployRelease(ctx, config, release, progress)
+
+	// 工作流被取消时仍然需要更新记录
+	recordCtx, _ = workflow.NewDisconnectedContext(recordCtx)
+	if recordErr := workflow.ExecuteActivity(recordCtx, RecordReleaseActivity, release.finish(ctx, err)).Get(recordCtx, nil); recordErr != nil {
+		workflow.GetLogger(ctx).Error("RecordReleaseActivity failed.", "Error", recordErr)
+	}
+	return err
+}
+
+// deployRelease 发布到全部主机，启用金丝雀时先发布金丝雀主机并分析指标，每台主机的结果记录到主机清单
+func deployRelease(ctx workflow.Context, config Config, release Release, progress *progressTracker) error {
+	logger := workflow.GetLogger(ctx)
+	hosts := config.releaseHosts()
+
+	var canaryHosts []string
+	if config.Canary.Enabled {
+		var err error
+		config.Canary = config.Canary.withDefaults()
+		canaryHosts, hosts, err = config.Canary.split(hosts)
+		if err != nil {
+			return err
+		}
+		if !config.canRollbackCanary() {
+			return fmt.Errorf("canary release needs a rollback command or versioned releases to roll back to")
+		}
+	}
+
+	// 金丝雀分析额外一个步骤
+	totalSteps := (len(canaryHosts) + len(hosts)) * stepsPerHost(config)
+	if len(canaryHosts) > 0 {
+		totalSteps++
+	}
+	progress.addSteps(totalSteps)
+
+	if len(canaryHosts) > 0 {
+		// 金丝雀主机切换前的版本，分析失败时切回
+		previous := make(map[string]string, len(canaryHosts))
+		for i, host := range canaryHosts {
+			var err error
+			if previous[host], err = releaseRecordedHost(ctx, config, release, host, progress); err != nil {
+				logger.Error("Canary host release failed, rolling back.", "Host", host, "Error", err)
+				return rollbackCanary(ctx, config, release, switchedCanaryHosts(config, canaryHosts, i, previous), previous, err, progress)
+			}
+		}
+
+		progress.enter(StepCanary)
+		analysis, err := canaryAnalysis(ctx, config, canaryHosts, hosts)
+		if err != nil {
+			logger.Error("Canary analysis failed, rolling back.", "Error", err)
+			return rollbackCanary(ctx, config, release, canaryHosts, previous, err, progress)
+		}
+		if !analysis.Passed {
+			err := fmt.Errorf("canary analysis failed: %s", strings.Join(analysis.Reasons, "; "))
+			logger.Error("Canary rejected, rolling back.", "Reasons", analysis.Reasons)
+			progress.logf("canary rejected: %s", strings.Join(analysis.Reasons, "; "))
+			return rollbackCanary(ctx, config, release, canaryHosts, previous, err, progress)
+		}
+		progress.logf("canary promoted: error rate %.4f vs %.4f, latency %.4fs vs %.4fs",
+			analysis.CanaryErrorRate, analysis.BaselineErrorRate, analysis.CanaryLatency, analysis.BaselineLatency)
+	}
+
+	for _, host := range hosts {
+		if _, err := releaseRecordedHost(ctx, config, release, host, progress); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("Release workflow completed successfully", "Version", config.Version)
+	progress.complete()
 	return nil
 }
 
-func ReleaseWorkflow(ctx workflow.Context, config Config) error {
-	ao := workflow.ActivityOptions{
-		StartToCloseTimeout: time.Minute,
+// rollbackCanary 金丝雀阶段失败时回滚已经切换的主机并记录为已回滚，返回导致回滚的错误
+func rollbackCanary(ctx workflow.Context, config Config, release Release, hosts []string, previous map[string]string, cause error, progress *progressTracker) error {
+	if len(hosts) == 0 {
+		progress.fail(cause)
+		return cause
+	}
+	err := cause
+	rollbackStarted := workflow.Now(ctx)
+	if rollbackErr := rollbackHosts(ctx, config, hosts, previous, progress); rollbackErr != nil {
+		err = fmt.Errorf("%v; rollback failed: %v", cause, rollbackErr)
+	} else {
+		recordHosts(ctx, config, release, hosts, ReleaseRolledBack, cause, rollbackStarted)
+	}
+	progress.fail(err)
+	return err
+}
+
+// switchedCanaryHosts 返回第 failed 台金丝雀主机发布失败时需要回滚的主机：之前发布成功的主机，
+// 以及失败前已经切换了 current 链接或配置了回滚命令的失败主机
+func switchedCanaryHosts(config Config, canaryHosts []string, failed int, previous map[string]string) []string {
+	hosts := append([]string(nil), canaryHosts[:failed]...)
+	host := canaryHosts[failed]
+	if config.Canary.RollbackCommand != "" || previous[host] != "" {
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// releaseRecordedHost 发布到单台主机并记录结果，返回主机切换前的版本
+func releaseRecordedHost(ctx workflow.Context, config Config, release Release, host string, progress *progressTracker) (string, error) {
+	started := workflow.Now(ctx)
+	previous, err := releaseHost(ctx, config.forHost(host), progress)
+	status := ReleaseSucceeded
+	if err != nil {
+		status = ReleaseFailed
+	}
+	recordHosts(ctx, config, release, []string{host}, status, err, started)
+	return previous, err
+}
+
+// stepsPerHost 返回每台主机发布的步骤数
+func stepsPerHost(config Config) int {
+	steps := 3
+	switch config.DeployMode {
+	case DeployModeBlueGreen:
+		steps = 6
+	case DeployModeSocketHandoff:
+		steps = 2
+	}
+	if config.Releases.Enabled {
+		steps++
+	}
+	return steps
+}
+
+// releaseHost 在单台主机上发布，启用版本目录时先切换 current 链接，成功后清理旧版本，返回切换前的版本
+func releaseHost(ctx workflow.Context, config Config, progress *progressTracker) (string, error) {
+	if !config.Releases.Enabled {
+		return "", restartHost(ctx, config, progress)
+	}
+	logger := workflow.GetLogger(ctx)
+
+	progress.enter(StepActivate)
+	var previous string
+	err := workflow.ExecuteActivity(withStep(ctx, config, StepActivate), ActivateReleaseActivity, config).Get(ctx, &previous)
+	if err != nil {
+		logger.Error("ActivateReleaseActivity failed.", "Host", config.ECSServer, "Error", err)
+		progress.fail(err)
+		return previous, err
+	}
+	progress.logf("%s current -> %s (previous %s)", config.ECSServer, config.Version, previous)
+
+	if err := restartHost(ctx, config, progress); err != nil {
+		return previous, err
+	}
+
+	// 清理失败不影响发布结果
+	var pruned []string
+	err = workflow.ExecuteActivity(withStep(ctx, config, StepPrune), PruneReleasesActivity, config).Get(ctx, &pruned)
+	if err != nil {
+		logger.Warn("PruneReleasesActivity failed.", "Host", config.ECSServer, "Error", err)
+		progress.logf("pruning old releases on %s failed: %v", config.ECSServer, err)
+	} else if len(pruned) > 0 {
+		progress.logf("pruned %v on %s", pruned, config.ECSServer)
+	}
+	return previous, nil
+}
+
+// restartHost 按发布模式在单台主机上重启应用，默认执行关停、重启和健康检查
+func restartHost(ctx workflow.Context, config Config, progress *progressTracker) error {
+	switch config.DeployMode {
+	case DeployModeBlueGreen:
+		return releaseHostBlueGreen(ctx, config, progress)
+	case DeployModeSocketHandoff:
+		return releaseHostHandoff(ctx, config, progress)
+	case DeployModeSystemd:
+		return releaseHostSystemd(ctx, config, progress)
 	}
-	ctx = workflow.WithActivityOptions(ctx, ao)
 	logger := workflow.GetLogger(ctx)
 
 	// 优雅关停
-	err := workflow.ExecuteActivity(ctx, GracefulShutdownActivity, config).Get(ctx, nil)
+	progress.enter(StepShutdown)
+	progress.logf("host %s", config.ECSServer)
+	err := workflow.ExecuteActivity(withStep(ctx, config, StepShutdown), GracefulShutdownActivity, config).Get(ctx, nil)
 	if err != nil {
-		logger.Error("GracefulShutdownActivity failed.", "Error", err)
+		logger.Error("GracefulShutdownActivity failed.", "Host", config.ECSServer, "Error", err)
+		progress.fail(err)
 		return err
 	}
 
 	// 重启应用
-	err = workflow.ExecuteActivity(ctx, RestartApplicationActivity, config).Get(ctx, nil)
+	progress.enter(StepRestart)
+	err = workflow.ExecuteActivity(withStep(ctx, config, StepRestart), RestartApplicationActivity, config).Get(ctx, nil)
 	if err != nil {
-		logger.Error("RestartApplicationActivity failed.", "Error", err)
+		logger.Error("RestartApplicationActivity failed.", "Host", config.ECSServer, "Error", err)
+		progress.fail(err)
 		return err
 	}
 
 	// 健康检查
-	err = workflow.ExecuteActivity(ctx, HealthCheckActivity, config).Get(ctx, nil)
+	progress.enter(StepHealthCheck)
+	var health HealthCheckResult
+	err = workflow.ExecuteActivity(withStep(ctx, config, StepHealthCheck), HealthCheckActivity, config).Get(ctx, &health)
 	if err != nil {
-		logger.Error("HealthCheckActivity failed.", "Error", err)
+		logger.Error("HealthCheckActivity failed.", "Host", config.ECSServer, "Error", err)
+		progress.fail(err)
 		return err
 	}
+	progress.logf("%s healthy after %d probes", config.ECSServer, health.Attempts)
+	return nil
+}
 
-	logger.Info("Release workflow completed successfully", "Version", config.Version)
+// releaseHostHandoff 通知应用进行 tableflip 升级，新进程接管监听套接字后再做健康检查
+func releaseHostHandoff(ctx workflow.Context, config Config, progress *progressTracker) error {
+	logger := workflow.GetLogger(ctx)
+
+	progress.enter(StepRestart)
+	var handoff HandoffResult
+	err := workflow.ExecuteActivity(withStep(ctx, config, StepRestart), SocketHandoffActivity, config).Get(ctx, &handoff)
+	if err != nil {
+		logger.Error("SocketHandoffActivity failed.", "Host", config.ECSServer, "Error", err)
+		progress.fail(err)
+		return err
+	}
+	progress.logf("%s handed off from pid %d to pid %d in %s", config.ECSServer, handoff.OldPID, handoff.NewPID, handoff.Duration)
+
+	progress.enter(StepHealthCheck)
+	var health HealthCheckResult
+	err = workflow.ExecuteActivity(withStep(ctx, config, StepHealthCheck), HealthCheckActivity, config).Get(ctx, &health)
+	if err != nil {
+		logger.Error("HealthCheckActivity failed.", "Host", config.ECSServer, "Error", err)
+		progress.fail(err)
+		return err
+	}
+	progress.logf("%s healthy after %d probes", config.ECSServer, health.Attempts)
+	return nil
+}
+
+// releaseHostSystemd 安装并重启 systemd 单元，健康检查后读取单元状态和最近日志写入发布报告
+func releaseHostSystemd(ctx workflow.Context, config Config, progress *progressTracker) error {
+	logger := workflow.GetLogger(ctx)
+
+	progress.enter(StepRestart)
+	err := workflow.ExecuteActivity(withStep(ctx, config, StepRestart), SystemdDeployActivity, config).Get(ctx, nil)
+	if err != nil {
+		logger.Error("SystemdDeployActivity failed.", "Host", config.ECSServer, "Error", err)
+		progress.fail(err)
+		return err
+	}
+
+	progress.enter(StepHealthCheck)
+	var health HealthCheckResult
+	healthErr := workflow.ExecuteActivity(withStep(ctx, config, StepHealthCheck), HealthCheckActivity, config).Get(ctx, &health)
+	if healthErr != nil {
+		logger.Error("HealthCheckActivity failed.", "Host", config.ECSServer, "Error", healthErr)
+	} else {
+		progress.logf("%s healthy after %d probes", config.ECSServer, health.Attempts)
+	}
+
+	// 健康检查失败时也读取单元状态，便于排查
+	progress.enter(StepUnitStatus)
+	var status SystemdStatus
+	err = workflow.ExecuteActivity(withStep(ctx, config, StepUnitStatus), SystemdStatusActivity, config).Get(ctx, &status)
+	if err != nil {
+		var appErr *temporal.ApplicationError
+		if errors.As(err, &appErr) && appErr.HasDetails() {
+			appErr.Details(&status)
+		}
+	}
+	progress.logf("%s %s: %s (%s), main pid %s", config.ECSServer, status.Unit, status.ActiveState, status.SubState, status.MainPID)
+	for _, line := range status.Journal {
+		progress.logf("journal: %s", line)
+	}
+
+	if healthErr != nil {
+		progress.fail(healthErr)
+		return healthErr
+	}
+	if err != nil {
+		logger.Error("SystemdStatusActivity failed.", "Host", config.ECSServer, "Error", err)
+		progress.fail(err)
+		return err
+	}
+	return nil
+}
+
+// releaseHostBlueGreen 在备用槽位启动新版本，健康检查通过后切换流量，排空后停止旧实例
+func releaseHostBlueGreen(ctx workflow.Context, config Config, progress *progressTracker) error {
+	logger := workflow.GetLogger(ctx)
+	spec := config.BlueGreen.withDefaults(config)
+
+	// 把新版本放进备用槽位
+	progress.enter(StepInstall)
+	var active string
+	err := workflow.ExecuteActivity(withStep(ctx, config, StepInstall), ActiveSlotActivity, config).Get(ctx, &active)
+	if err != nil {
+		logger.Error("ActiveSlotActivity failed.", "Host", config.ECSServer, "Error", err)
+		progress.fail(err)
+		return err
+	}
+	current, target := spec.slots(active)
+	progress.logf("host %s: %s is live, installing %s into %s", config.ECSServer, current.Name, config.Version, target.Dir)
+	err = workflow.ExecuteActivity(withStep(ctx, config, StepInstall), RemoteCommandActivity, config, spec.render(spec.InstallCommand, config, target)).Get(ctx, nil)
+	if err != nil {
+		logger.Error("Installing into slot failed.", "Host", config.ECSServer, "Slot", target.Name, "Error", err)
+		progress.fail(err)
+		return err
+	}
+
+	// 启动新槽位
+	progress.enter(StepRestart)
+	progress.logf("starting %s on port %d", target.Name, target.Port)
+	err = workflow.ExecuteActivity(withStep(ctx, config, StepRestart), RemoteCommandActivity, config, spec.render(spec.StartCommand, config, target)).Get(ctx, nil)
+	if err != nil {
+		logger.Error("Starting new slot failed.", "Host", config.ECSServer, "Slot", target.Name, "Error", err)
+		progress.fail(err)
+		return err
+	}
+
+	// 检查新槽位，失败时停止新实例，旧实例继续提供服务
+	progress.enter(StepHealthCheck)
+	var health HealthCheckResult
+	err = workflow.ExecuteActivity(withStep(ctx, config, StepHealthCheck), HealthCheckActivity, config.forSlot(target)).Get(ctx, &health)
+	if err != nil {
+		logger.Error("HealthCheckActivity failed.", "Host", config.ECSServer, "Slot", target.Name, "Error", err)
+		stopErr := workflow.ExecuteActivity(withStep(ctx, config, StepRetire), RemoteCommandActivity, config, spec.render(spec.StopCommand, config, target)).Get(ctx, nil)
+		if stopErr != nil {
+			err = fmt.Errorf("%v; stopping %s failed: %v", err, target.Name, stopErr)
+		}
+		progress.fail(err)
+		return err
+	}
+	progress.logf("%s slot %s healthy after %d probes", config.ECSServer, target.Name, health.Attempts)
+
+	// 切换流量
+	progress.enter(StepSwitch)
+	err = workflow.ExecuteActivity(withStep(ctx, config, StepSwitch), SwitchTrafficActivity, config, target).Get(ctx, nil)
+	if err != nil {
+		logger.Error("SwitchTrafficActivity failed.", "Host", config.ECSServer, "Error", err)
+		progress.fail(err)
+		return err
+	}
+
+	// 等待旧实例处理完存量请求
+	progress.enter(StepDrain)
+	progress.logf("draining %s for %s", current.Name, spec.DrainPeriod)
+	if err := workflow.Sleep(ctx, spec.DrainPeriod); err != nil {
+		progress.fail(err)
+		return err
+	}
+
+	// 停止旧实例
+	progress.enter(StepRetire)
+	err = workflow.ExecuteActivity(withStep(ctx, config, StepRetire), RemoteCommandActivity, config, spec.render(spec.StopCommand, config, current)).Get(ctx, nil)
+	if err != nil {
+		logger.Error("Retiring old slot failed.", "Host", config.ECSServer, "Slot", current.Name, "Error", err)
+		progress.fail(err)
+		return err
+	}
 	return nil
 }
+
+// canaryAnalysis 在观察窗口前后抓取指标，用持久化定时器等待窗口结束
+func canaryAnalysis(ctx workflow.Context, config Config, canaryHosts, baselineHosts []string) (CanaryAnalysis, error) {
+	spec := config.Canary
+	allHosts := append(append([]string{}, canaryHosts...), baselineHosts...)
+	ctx = withStep(ctx, config, StepCanary)
+
+	var before, after map[string]MetricsSnapshot
+	if err := workflow.ExecuteActivity(ctx, ScrapeMetricsActivity, spec, allHosts).Get(ctx, &before); err != nil {
+		return CanaryAnalysis{}, err
+	}
+	if err := workflow.Sleep(ctx, spec.Window); err != nil {
+		return CanaryAnalysis{}, err
+	}
+	if err := workflow.ExecuteActivity(ctx, ScrapeMetricsActivity, spec, allHosts).Get(ctx, &after); err != nil {
+		return CanaryAnalysis{}, err
+	}
+	return analyzeCanary(spec, before, after, canaryHosts, baselineHosts), nil
+}
+
+// rollbackHosts 在金丝雀主机上执行回滚命令，未配置时把 current 切回 previous 中记录的版本并重启
+func rollbackHosts(ctx workflow.Context, config Config, hosts []string, previous map[string]string, progress *progressTracker) error {
+	progress.enter(StepRollback)
+	started := workflow.Now(ctx)
+	event := newEvent(ctx, EventRollback, config, started, nil)
+	event.Type, event.Status, event.Hosts = EventRollback+".started", ReleaseRunning, hosts
+	publishEvent(ctx, config, event)
+
+	var err error
+	for _, host := range hosts {
+		hostConfig := config.forHost(host)
+		if config.Canary.RollbackCommand != "" {
+			err = workflow.ExecuteActivity(withStep(ctx, config, StepRollback), RemoteCommandActivity, hostConfig, config.Canary.RollbackCommand).Get(ctx, nil)
+		} else {
+			err = rollbackRelease(ctx, hostConfig, previous[host], progress)
+		}
+		if err != nil {
+			break
+		}
+		progress.logf("rolled back %s", host)
+	}
+
+	event = newEvent(ctx, EventRollback, config, started, err)
+	event.Hosts = hosts
+	publishEvent(ctx, config, event)
+	return err
+}
+
+// rollbackRelease 把主机的 current 链接切回 version 并重启应用
+func rollbackRelease(ctx workflow.Context, config Config, version string, progress *progressTracker) error {
+	if version == "" {
+		return fmt.Errorf("no previous release on %s to roll back to", config.ECSServer)
+	}
+	progress.logf("rolling back %s to %s", config.ECSServer, version)
+	progress.addSteps(stepsPerHost(config))
+	config.Version = version
+	_, err := releaseHost(ctx, config, progress)
+	return err
+}
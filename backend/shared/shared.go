@@ -3,6 +3,8 @@ package shared
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -21,6 +23,11 @@ type Configuration struct {
 	Database DatabaseConfig
 	Server   ServerConfig
 	Log      LogConfig
+	// 按步骤配置活动超时和重试，键为步骤名（build、test、restart 等）
+	Activities map[string]ActivityConfig
+	Notify     NotifyConfig
+	Temporal   TemporalConfig
+	Worker     WorkerConfig
 }
 
 // DatabaseConfig struct for database configuration
@@ -35,18 +42,123 @@ type DatabaseConfig struct {
 // ServerConfig struct for server configuration
 type ServerConfig struct {
 	Port int
+	// 管理员令牌，绕过发布策略和修改策略时通过 X-Admin-Token 请求头提供，为空时禁用这些操作
+	AdminToken string `mapstructure:"admin_token"`
 }
 
 // LogConfig struct for log configuration
 type LogConfig struct {
 	Level  string
 	Format string
+	// 活动输出日志目录及单个日志文件的大小上限
+	ActivityDir      string `mapstructure:"activity_dir"`
+	ActivityMaxBytes int64  `mapstructure:"activity_max_bytes"`
 }
 
+// TemporalConfig struct for the Temporal frontend connection
+type TemporalConfig struct {
+	HostPort  string `mapstructure:"host_port"`
+	Namespace string
+	// API 启动工作流使用的任务队列，Worker 未配置 task_queues 时也轮询这个队列
+	TaskQueue string `mapstructure:"task_queue"`
+	// 客户端身份，显示在工作流历史和 Worker 列表中，为空时使用 SDK 默认的 pid@hostname
+	Identity string
+	// Temporal Cloud 等服务的 API Key，以 Bearer 令牌发送，配置后自动启用 TLS
+	APIKey string `mapstructure:"api_key"`
+	TLS    TLSConfig
+}
+
+// TLSConfig struct for the TLS connection to Temporal
+type TLSConfig struct {
+	Enabled bool
+	// 自签名证书的 CA，为空时使用系统根证书
+	CAFile     string `mapstructure:"ca_file"`
+	ServerName string `mapstructure:"server_name"`
+	// mTLS 客户端证书和私钥，配置后自动启用 TLS
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+}
+
+// WorkerConfig struct for the worker process
+type WorkerConfig struct {
+	// 轮询的任务队列，为空时使用 temporal.task_queue
+	TaskQueues []string `mapstructure:"task_queues"`
+	// 并发执行的活动和工作流任务上限，0 使用 SDK 默认值
+	MaxConcurrentActivities    int `mapstructure:"max_concurrent_activities"`
+	MaxConcurrentWorkflowTasks int `mapstructure:"max_concurrent_workflow_tasks"`
+	// 克隆仓库和构建产物的工作目录
+	WorkspaceDir string `mapstructure:"workspace_dir"`
+	// 按 sha256 保存已构建产物的目录，晋级时从这里取回；多台 Worker 时需要是共享存储，为空时使用工作目录下的 artifacts
+	ArtifactDir string `mapstructure:"artifact_dir"`
+	// 收到 SIGTERM 后等待运行中活动完成的时间，超时后取消活动
+	ShutdownGracePeriod time.Duration `mapstructure:"shutdown_grace_period"`
+	// 活动取消后等待外部命令（go build、ssh 等）响应 SIGTERM 的时间，超时后 SIGKILL
+	ProcessKillGrace time.Duration `mapstructure:"process_kill_grace"`
+}
+
+// NotifyConfig struct for notification delivery
+type NotifyConfig struct {
+	SMTP SMTPConfig
+}
+
+// SMTPConfig struct for the mail server used by email notifications
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// ActivityConfig struct for per-step activity timeouts and retry policy
+type ActivityConfig struct {
+	StartToCloseTimeout time.Duration `mapstructure:"start_to_close_timeout"`
+	HeartbeatTimeout    time.Duration `mapstructure:"heartbeat_timeout"`
+	Retry               *RetryConfig
+}
+
+// RetryConfig struct for activity retry policy
+type RetryConfig struct {
+	InitialInterval        time.Duration `mapstructure:"initial_interval"`
+	BackoffCoefficient     float64       `mapstructure:"backoff_coefficient"`
+	MaximumInterval        time.Duration `mapstructure:"maximum_interval"`
+	MaximumAttempts        int32         `mapstructure:"maximum_attempts"`
+	NonRetryableErrorTypes []string      `mapstructure:"non_retryable_error_types"`
+}
+
+// configDefaults 未写在配置文件中的默认值；设置默认值后对应的环境变量才会生效
+var configDefaults = map[string]interface{}{
+	"temporal.host_port":                   "localhost:7233",
+	"temporal.namespace":                   "default",
+	"temporal.task_queue":                  "release-task-queue",
+	"temporal.identity":                    "",
+	"temporal.api_key":                     "",
+	"temporal.tls.enabled":                 false,
+	"temporal.tls.ca_file":                 "",
+	"temporal.tls.server_name":             "",
+	"temporal.tls.cert_file":               "",
+	"temporal.tls.key_file":                "",
+	"worker.task_queues":                   []string{},
+	"worker.max_concurrent_activities":     0,
+	"worker.max_concurrent_workflow_tasks": 0,
+	"worker.workspace_dir":                 "reposity",
+	"worker.artifact_dir":                  "",
+	"worker.shutdown_grace_period":         30 * time.Second,
+	"worker.process_kill_grace":            10 * time.Second,
+}
+
+// InitConfig 读取 backend/config/config.yaml，任意配置项都可以用 AONE_ 前缀的环境变量覆盖，
+// 例如 AONE_TEMPORAL_HOST_PORT 覆盖 temporal.host_port
 func InitConfig() {
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath("backend/config")
+	viper.SetEnvPrefix("AONE")
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
+	for key, value := range configDefaults {
+		viper.SetDefault(key, value)
+	}
 	err := viper.ReadInConfig()
 	if err != nil {
 		panic(fmt.Sprintf("Error reading config file: %s", err))
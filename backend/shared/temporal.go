@@ -0,0 +1,60 @@
+package shared
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"go.temporal.io/sdk/client"
+)
+
+// TemporalClientOptions 按配置生成 Temporal 客户端选项：命名空间、TLS/mTLS、身份和 API Key
+func TemporalClientOptions(cfg TemporalConfig) (client.Options, error) {
+	options := client.Options{
+		HostPort:  cfg.HostPort,
+		Namespace: cfg.Namespace,
+		Identity:  cfg.Identity,
+	}
+	if cfg.APIKey != "" {
+		options.Credentials = client.NewAPIKeyStaticCredentials(cfg.APIKey)
+	}
+
+	// 配置了客户端证书或 API Key 时即使未显式开启也使用 TLS，避免明文发送凭据
+	tlsCfg := cfg.TLS
+	if !tlsCfg.Enabled && tlsCfg.CertFile == "" && tlsCfg.KeyFile == "" && cfg.APIKey == "" {
+		return options, nil
+	}
+	tlsConfig := &tls.Config{ServerName: tlsCfg.ServerName}
+	if tlsCfg.CAFile != "" {
+		pem, err := os.ReadFile(tlsCfg.CAFile)
+		if err != nil {
+			return options, fmt.Errorf("read CA file error: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return options, fmt.Errorf("no certificates found in %s", tlsCfg.CAFile)
+		}
+	}
+	if tlsCfg.CertFile != "" || tlsCfg.KeyFile != "" {
+		if tlsCfg.CertFile == "" || tlsCfg.KeyFile == "" {
+			return options, fmt.Errorf("mTLS needs both cert_file and key_file")
+		}
+		cert, err := tls.LoadX509KeyPair(tlsCfg.CertFile, tlsCfg.KeyFile)
+		if err != nil {
+			return options, fmt.Errorf("load client certificate error: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	options.ConnectionOptions.TLS = tlsConfig
+	return options, nil
+}
+
+// NewTemporalClient 用全局配置连接 Temporal，API 和 Worker 共用
+func NewTemporalClient() (client.Client, error) {
+	options, err := TemporalClientOptions(Config.Temporal)
+	if err != nil {
+		return nil, err
+	}
+	return client.Dial(options)
+}
@@ -0,0 +1,110 @@
+package shared
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert 生成自签名证书和私钥，返回文件路径
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "temporal-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, "client.pem")
+	keyFile = filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestTemporalClientOptions(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir)
+	garbage := filepath.Join(dir, "garbage.pem")
+	if err := os.WriteFile(garbage, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		cfg       TemporalConfig
+		wantTLS   bool
+		wantCerts int
+		wantCA    bool
+		wantCreds bool
+		wantErr   bool
+	}{
+		{"plaintext", TemporalConfig{}, false, 0, false, false, false},
+		{"tls enabled", TemporalConfig{TLS: TLSConfig{Enabled: true, ServerName: "temporal"}}, true, 0, false, false, false},
+		{"api key enables tls", TemporalConfig{APIKey: "secret"}, true, 0, false, true, false},
+		{"custom ca", TemporalConfig{TLS: TLSConfig{Enabled: true, CAFile: certFile}}, true, 0, true, false, false},
+		{"missing ca", TemporalConfig{TLS: TLSConfig{Enabled: true, CAFile: filepath.Join(dir, "missing.pem")}}, false, 0, false, false, true},
+		{"ca without certificates", TemporalConfig{TLS: TLSConfig{Enabled: true, CAFile: garbage}}, false, 0, false, false, true},
+		{"mtls enables tls", TemporalConfig{TLS: TLSConfig{CertFile: certFile, KeyFile: keyFile}}, true, 1, false, false, false},
+		{"cert without key", TemporalConfig{TLS: TLSConfig{CertFile: certFile}}, false, 0, false, false, true},
+		{"key without cert", TemporalConfig{TLS: TLSConfig{KeyFile: keyFile}}, false, 0, false, false, true},
+		{"invalid key pair", TemporalConfig{TLS: TLSConfig{CertFile: certFile, KeyFile: garbage}}, false, 0, false, false, true},
+	}
+
+	for _, tt := range tests {
+		options, err := TemporalClientOptions(tt.cfg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: TemporalClientOptions() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		tlsConfig := options.ConnectionOptions.TLS
+		if (tlsConfig != nil) != tt.wantTLS {
+			t.Errorf("%s: TLS = %v, want %v", tt.name, tlsConfig != nil, tt.wantTLS)
+			continue
+		}
+		if (options.Credentials != nil) != tt.wantCreds {
+			t.Errorf("%s: credentials = %v, want %v", tt.name, options.Credentials != nil, tt.wantCreds)
+		}
+		if tlsConfig == nil {
+			continue
+		}
+		if tlsConfig.ServerName != tt.cfg.TLS.ServerName {
+			t.Errorf("%s: ServerName = %q, want %q", tt.name, tlsConfig.ServerName, tt.cfg.TLS.ServerName)
+		}
+		if len(tlsConfig.Certificates) != tt.wantCerts {
+			t.Errorf("%s: %d client certificates, want %d", tt.name, len(tlsConfig.Certificates), tt.wantCerts)
+		}
+		if (tlsConfig.RootCAs != nil) != tt.wantCA {
+			t.Errorf("%s: custom CA = %v, want %v", tt.name, tlsConfig.RootCAs != nil, tt.wantCA)
+		}
+	}
+}
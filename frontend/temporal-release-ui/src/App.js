@@ -1,4 +1,4 @@
-import React, { useState } from "react";
+import React, { useEffect, useRef, useState } from "react";
 
 const App = () => {
     const [repoURL, setRepoURL] = useState("");
@@ -11,6 +11,60 @@ const App = () => {
     const [ecsUser, setECSUser] = useState("");
     const [ecsPassword, setECSPassword] = useState("");
     const [healthCheckURL, setHealthCheckURL] = useState("");
+    const [workflowID, setWorkflowID] = useState("");
+    const [stage, setStage] = useState(null);
+    const [status, setStatus] = useState("");
+    const [logs, setLogs] = useState([]);
+    const eventSourceRef = useRef(null);
+
+    useEffect(() => {
+        return () => {
+            if (eventSourceRef.current) {
+                eventSourceRef.current.close();
+            }
+        };
+    }, []);
+
+    // 订阅工作流的 SSE 事件流，展示阶段切换和日志
+    const watchWorkflow = (data) => {
+        if (eventSourceRef.current) {
+            eventSourceRef.current.close();
+        }
+        if (!data.workflow_id) {
+            setStatus(data.error || "failed to start workflow");
+            return;
+        }
+        setWorkflowID(data.workflow_id);
+        setStage(null);
+        setStatus("running");
+        setLogs([]);
+
+        const source = new EventSource(
+            `/api/workflows/${encodeURIComponent(data.workflow_id)}/events?run_id=${encodeURIComponent(data.run_id)}`
+        );
+        const appendLog = (line) => setLogs((prev) => [...prev, line]);
+        source.addEventListener("stage", (e) => setStage(JSON.parse(e.data)));
+        source.addEventListener("log", (e) => {
+            const line = JSON.parse(e.data);
+            appendLog(`[${line.Stage}] ${line.Message}`);
+        });
+        source.addEventListener("heartbeat", (e) => {
+            const hb = JSON.parse(e.data);
+            appendLog(`[${hb.activity}] ${hb.message}`);
+        });
+        source.addEventListener("done", (e) => {
+            const done = JSON.parse(e.data);
+            setStatus(done.status);
+            source.close();
+        });
+        source.addEventListener("error", (e) => {
+            if (e.data) {
+                setStatus(JSON.parse(e.data).error);
+            }
+            source.close();
+        });
+        eventSourceRef.current = source;
+    };
 
     const handleStartConfig = async () => {
         const response = await fetch("/api/start-config", {
@@ -33,7 +87,7 @@ const App = () => {
         });
 
         const data = await response.json();
-        console.log(data);
+        watchWorkflow(data);
     };
 
     const handleStartBuildUpload = async () => {
@@ -57,7 +111,7 @@ const App = () => {
         });
 
         const data = await response.json();
-        console.log(data);
+        watchWorkflow(data);
     };
 
     const handleStartRelease = async () => {
@@ -81,7 +135,7 @@ const App = () => {
         });
 
         const data = await response.json();
-        console.log(data);
+        watchWorkflow(data);
     };
 
     return (
@@ -172,6 +226,16 @@ const App = () => {
                 <button onClick={handleStartBuildUpload}>Start Build & Upload Workflow</button>
                 <button onClick={handleStartRelease}>Start Release Workflow</button>
             </div>
+            {workflowID && (
+                <div>
+                    <h2>Workflow {workflowID}</h2>
+                    <p>
+                        Status: {status}
+                        {stage && ` — ${stage.stage} (${stage.step}/${stage.total_steps})`}
+                    </p>
+                    <pre>{logs.join("\n")}</pre>
+                </div>
+            )}
         </div>
     );
 };